@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// Checkpoint records how far `wikipath index` has got through an archive,
+// so an interrupted run can be resumed.
+type Checkpoint struct {
+	Archive  string  `json:"archive"`  // Path of the wiki archive being indexed.
+	Format   string  `json:"format"`   // Format of the *.wpindex being written.
+	Ordered  bool    `json:"ordered"`  // If articles are written in archive order.
+	Size     int64   `json:"size"`     // Bytes of the output which are complete.
+	Articles int     `json:"articles"` // Articles in those bytes.
+	Chunks   []int64 `json:"chunks"`   // Start offsets of the archive chunks in those bytes.
+}
+
+// LoadCheckpoint reads a Checkpoint from a file.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// Save writes the Checkpoint to a file, replacing it atomically.
+func (cp *Checkpoint) Save(path string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Done returns the set of chunks the Checkpoint covers.
+func (cp *Checkpoint) Done() map[int64]bool {
+	done := make(map[int64]bool, len(cp.Chunks))
+	for _, start := range cp.Chunks {
+		done[start] = true
+	}
+	return done
+}
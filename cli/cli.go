@@ -12,7 +12,7 @@ func main() {
 	app.HelpName = app.Name
 	app.Usage = "Find a path of links between two wiki pages."
 
-	app.Commands = []cli.Command{IndexCmd, IndexShowCmd, StartCmd}
+	app.Commands = []cli.Command{IndexCmd, IndexShowCmd, IndexVerifyCmd, IndexDiffCmd, IndexExtractCmd, ExportCmd, PhilosophyCmd, StatsCmd, StartCmd}
 
 	app.Run(os.Args)
 }
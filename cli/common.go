@@ -3,12 +3,16 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/urfave/cli"
+
+	. "github.com/wgoodall01/wikipath/wp"
 )
 
 // NewFileError creates an error for a file I/O issue.
@@ -29,6 +33,12 @@ func NewUsageError(msg string, args ...interface{}) *cli.ExitError {
 	return cli.NewExitError("wikipath: "+fmt.Sprintf(msg, args...)+"\n", 3)
 }
 
+// NewInterruptError creates an error for a command stopped by a signal.
+// Exits with code `130`.
+func NewInterruptError(msg string, args ...interface{}) *cli.ExitError {
+	return cli.NewExitError("wikipath: "+fmt.Sprintf(msg, args...)+"\n", 130)
+}
+
 // Prompt prompts the user for input on stdin, which it then returns.
 func Prompt(prompt string) string {
 	in := bufio.NewReader(os.Stdin)
@@ -37,12 +47,16 @@ func Prompt(prompt string) string {
 	return strings.TrimSpace(valRaw)
 }
 
+// Progress is where progress is printed as indexes load. Commands which
+// print something for other programs to read send it to stderr instead.
+var Progress io.Writer = os.Stdout
+
 // PrintTicker prints a line which overwrites the last on a tty.
 func PrintTicker(prompt string, tick string) {
 	width := 100
 	status := prompt + tick + strings.Repeat(" ", width)
 	status = status[:width] + "\r"
-	fmt.Print(status)
+	fmt.Fprint(Progress, status)
 }
 
 // RateMeasure measures how frequently something happens.
@@ -97,10 +111,101 @@ func (rm *RateMeasure) Average() float32 {
 	return rm.average
 }
 
+// LoadIndex loads a `*.wpindex` file into an Index and builds it,
+// printing progress as it goes. Only pages in the namespaces `namespaces`
+// includes are loaded. Where each link is, its text, sentence and section,
+// is only kept if `contexts` is set, as it takes a lot of memory and is
+// only needed to print the hops of a path.
+func LoadIndex(indexPath string, namespaces *NamespaceFilter, contexts bool) (*Index, error) {
+	// Open the index
+	indexFile, indexErr := os.Open(indexPath)
+	if indexErr != nil {
+		return nil, NewFileError("Could not open index file '%s'", indexPath)
+	}
+	defer indexFile.Close()
+
+	// Create WpindexReader
+	reader, readerErr := NewWpindexReader(indexFile)
+	if readerErr != nil {
+		return nil, NewFileError("Could not understand index.")
+	}
+
+	PrintTicker("Loading wpindex...  ", "")
+
+	// Load all the articles.
+	tLoad := time.Now()
+	ind := NewIndex()
+	ind.SetNamespaces(namespaces)
+
+	articles := make(chan *StrippedArticle, 512)
+	ec := NewErrorContext()
+	ec.Start()
+	go func() {
+		n := 0
+		rate := NewRateMeasure(0.5)
+		for sa := range articles {
+			n++
+			rate.Count(1)
+			if n%500 == 0 {
+				PrintTicker("Loading wpindex...  ", fmt.Sprintf("[rate:%4.2f  article:%d  title: %s]", rate.Average(), sa.ID, sa.Title))
+			}
+			if !contexts {
+				sa.DropContext()
+				sa.Fragments, sa.Sections = nil, nil
+			}
+			ind.AddArticle(sa)
+		}
+		rate.Stop()
+		ec.Done()
+	}()
+
+	var wpindexErr error = nil
+	for {
+		var sa *StrippedArticle
+		sa, wpindexErr = reader.ReadArticle()
+		if wpindexErr != nil {
+			break
+		}
+		articles <- sa
+	}
+	close(articles)
+
+	ec.Wait()
+
+	if wpindexErr != nil && wpindexErr != EOF {
+		return nil, cli.NewExitError("Error loading .wpindex file: "+wpindexErr.Error()+"\n(run 'wikipath index-verify' for details)", 2)
+	}
+
+	closeErr := reader.Close()
+	if closeErr != nil {
+		return nil, cli.NewExitError("Couldn't close .wpindex reader", 3)
+	}
+
+	dLoad := time.Since(tLoad).Seconds()
+	PrintTicker("Loading wpindex...  ", fmt.Sprintf("[done in %4.2fs]", dLoad))
+	fmt.Fprintln(Progress)
+
+	// Index all the articles.
+	fmt.Fprint(Progress, "Making index...     ")
+	tBuild := time.Now()
+	ind.Build()
+	dBuild := time.Since(tBuild).Seconds()
+	fmt.Fprintf(Progress, "[done in %4.2fs]\n", dBuild)
+
+	// Run a GC
+	fmt.Fprint(Progress, "Running GC...       ")
+	runtime.GC()
+	fmt.Fprintf(Progress, "[done]\n")
+
+	return ind, nil
+}
+
 type flags struct {
 	WikiArchivePath cli.StringFlag
 	WikiIndexPath   cli.StringFlag
 	WpindexPath     cli.StringFlag
+	WpindexFormat   cli.StringFlag
+	Namespaces      cli.StringFlag
 }
 
 // WpFlags are CLI flags shared between subcommands.
@@ -111,16 +216,26 @@ var WpFlags = flags{
 		EnvVar: "WPINDEX_PATH",
 		Value:  "./wikis/enwiki.wpindex",
 	},
+	WpindexFormat: cli.StringFlag{
+		Name:  "format",
+		Usage: "Encoding for the *.wpindex file, 'gob' or 'compact'",
+		Value: "gob",
+	},
 	WikiArchivePath: cli.StringFlag{
 		Name:   "wiki-archive, wa",
-		Usage:  "Wiki archive *-multistream.xml.bz2 file.",
+		Usage:  "Wiki archive: *-multistream.xml.bz2, plain, gzipped or bzipped XML, a ZIM file (zlib or bzip2, not xz or zstd), or an HTML dump.",
 		EnvVar: "WIKI_ARCHIVE_PATH",
 		Value:  "./wikis/enwiki-multistream.xml.bz2",
 	},
 	WikiIndexPath: cli.StringFlag{
 		Name:   "wiki-index, wi",
-		Usage:  "Wiki index *-multistream-index.txt.bz2 file.",
+		Usage:  "Wiki index *-multistream-index.txt.bz2 file. Optional, but saves scanning the archive. The default is only used for the default --wiki-archive.",
 		EnvVar: "WIKI_INDEX_PATH",
 		Value:  "./wikis/enwiki-multistream-index.txt.bz2",
 	},
+	Namespaces: cli.StringFlag{
+		Name:  "namespaces, ns",
+		Usage: "Namespaces to include, like 'main,portal', '0,100' or '-talk,-user'",
+		Value: "main",
+	},
 }
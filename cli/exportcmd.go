@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	. "github.com/wgoodall01/wikipath/wp"
+)
+
+// ExportCmd is the CLI command to export the link graph for other tools.
+var ExportCmd = cli.Command{
+	Name:  "export",
+	Usage: "Write the link graph to a file, for Gephi, networkx, igraph or Graphviz.",
+	Flags: []cli.Flag{
+		WpFlags.WpindexPath,
+		WpFlags.Namespaces,
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "Graph format: " + exportFormatNames(),
+			Value: string(ExportCSV),
+		},
+		cli.StringFlag{
+			Name:  "out, o",
+			Usage: "Path to write the graph to",
+		},
+		cli.StringSliceFlag{
+			Name:  "around, a",
+			Usage: "Only export the articles near this one (repeatable)",
+		},
+		cli.IntFlag{
+			Name:  "radius, r",
+			Usage: "With --around, include articles up to this many links away",
+			Value: 1,
+		},
+	},
+	Action: func(c *cli.Context) error {
+		format, formatErr := ParseExportFormat(c.String("format"))
+		if formatErr != nil {
+			return NewUsageError("%v", formatErr)
+		}
+
+		outPath := c.String("out")
+		if outPath == "" {
+			return NewUsageError("An output file is required, with --out")
+		}
+
+		namespaces, nsErr := ParseNamespaces(c.String("namespaces"), DefaultLinkParser)
+		if nsErr != nil {
+			return NewUsageError("%v", nsErr)
+		}
+
+		ind, loadErr := LoadIndex(c.String("wpindex"), namespaces, false)
+		if loadErr != nil {
+			return loadErr
+		}
+
+		items := ind.Items()
+		if around := c.StringSlice("around"); len(around) > 0 {
+			var seeds []*IndexItem
+			for _, title := range around {
+				item := ind.Get(title)
+				if item == nil {
+					return NewUsageError("Can't find article '%s'", title)
+				}
+				seeds = append(seeds, item)
+			}
+
+			items = items[:0]
+			for item := range ind.Ball(seeds, c.Int("radius")) {
+				items = append(items, item)
+			}
+		}
+
+		outFile, outErr := os.Create(outPath)
+		if outErr != nil {
+			return NewFileError("Could not open output file '%s'", outPath)
+		}
+		defer outFile.Close()
+
+		fmt.Printf("Exporting %d articles...  ", len(items))
+		tStart := time.Now()
+		if exportErr := ind.ExportGraph(outFile, format, items); exportErr != nil {
+			return NewFileError("Could not write graph: %v", exportErr)
+		}
+		if closeErr := outFile.Close(); closeErr != nil {
+			return NewFileError("Could not write graph: %v", closeErr)
+		}
+		fmt.Printf("[done in %4.2fs]\n", time.Since(tStart).Seconds())
+
+		return nil
+	},
+}
+
+func exportFormatNames() string {
+	names := make([]string, len(ExportFormats))
+	for i, f := range ExportFormats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/urfave/cli"
@@ -10,80 +14,511 @@ import (
 	. "github.com/wgoodall01/wikipath/wp"
 )
 
+// ErrInterrupted is the error an index run is canceled with on SIGINT.
+var ErrInterrupted = errors.New("interrupted")
+
 // IndexCmd is the command to start building a `*.wpindex` file from
 // a wiki archive.
+//
+// The index is written to `<wpindex>.tmp`, and renamed into place once it's
+// complete. Every few chunks of the archive it checkpoints the temp file to
+// `<wpindex>.checkpoint`, which `--resume` picks up from. With `--ordered`,
+// checkpoints fall on the same chunks every run, so a resumed index is
+// byte-identical to one built in one go.
+//
+// The archive can also be plain, gzipped or single-stream bzip2 XML, like
+// Special:Export gives, a Kiwix ZIM file, or a Wikimedia Enterprise HTML
+// dump. ZIM files compressed with xz or zstd, as most Kiwix publishes are,
+// aren't supported, and are turned away before anything is written. These are read in one go, and can't be resumed. A multistream
+// archive without its index has its streams found by scanning.
+//
+// With `--summaries`, the lead paragraph of each article is written as plain
+// text to `<wpindex>.summaries`, keyed by page ID. That needs wikitext, and
+// can't be resumed.
+//
+// With `--sql-page` and friends, it reads MediaWiki's SQL table dumps instead
+// of the archive, which is much quicker as no wikitext needs parsing.
 var IndexCmd = cli.Command{
 	Name:  "index",
 	Usage: "Build an intermediate index of articles.",
-	Flags: []cli.Flag{WpFlags.WpindexPath, WpFlags.WikiArchivePath, WpFlags.WikiIndexPath},
+	Flags: append([]cli.Flag{
+		WpFlags.WpindexPath,
+		WpFlags.WpindexFormat,
+		WpFlags.WikiArchivePath,
+		WpFlags.WikiIndexPath,
+		WpFlags.Namespaces,
+		cli.BoolFlag{
+			Name:  "resume",
+			Usage: "Continue an interrupted run from its last checkpoint",
+		},
+		cli.BoolFlag{
+			Name:  "ordered",
+			Usage: "Write articles in archive order, so the output is the same on every run",
+		},
+		cli.IntFlag{
+			Name:  "checkpoint-every",
+			Usage: "Checkpoint after this many archive chunks",
+			Value: 1000,
+		},
+		cli.BoolFlag{
+			Name:  "no-context",
+			Usage: "Leave out the text and sentence of each link, for a much smaller *.wpindex",
+		},
+		cli.BoolFlag{
+			Name:  "summaries",
+			Usage: "Also write the lead paragraph of each article to <wpindex>.summaries",
+		},
+	}, sqlFlags...),
 	Action: func(c *cli.Context) error {
-		// Open the archive and index
+		format, formatErr := ParseWpindexFormat(c.String("format"))
+		if formatErr != nil {
+			return NewUsageError("%v", formatErr)
+		}
+
+		if c.String("sql-page") != "" {
+			if c.Bool("summaries") {
+				return NewUsageError("--summaries needs a wikitext archive, not SQL dumps")
+			}
+			return indexFromSQL(c, format)
+		}
+
+		// Open the archive, and work out what it is.
 		archivePath := c.String("wiki-archive")
 		archiveFile, fileErr := os.Open(archivePath)
 		if fileErr != nil {
 			return NewFileError("Could not open wiki archive '%s'", archivePath)
 		}
+		defer archiveFile.Close()
+
+		archiveInfo, statErr := archiveFile.Stat()
+		if statErr != nil {
+			return NewFileError("Could not open wiki archive '%s'", archivePath)
+		}
+		kind, kindErr := DetectArchive(archiveFile, archiveInfo.Size())
+		if kindErr != nil {
+			return NewFileError("Could not read wiki archive '%s': %v", archivePath, kindErr)
+		}
+
+		// Only ZIM files with clusters the standard library can decompress
+		// can be read, which has to be checked before writing anything.
+		var zim *ZimReader
+		if kind == ArchiveZim {
+			var zimErr error
+			if zim, zimErr = NewZimReader(archiveFile); zimErr != nil {
+				return NewFileError("Could not read ZIM file '%s': %v", archivePath, zimErr)
+			}
+			if compressionErr := zim.CheckCompression(); compressionErr != nil {
+				return NewFileError("Could not read ZIM file '%s': %v", archivePath, compressionErr)
+			}
+		}
+
+		// Namespaces are named in the <siteinfo> at the start of the XML, for
+		// wikis in other languages. Without one, the English names are used.
+		lp := DefaultLinkParser
+		if kind != ArchiveZim && kind != ArchiveHTMLDump {
+			head, headErr := OpenArchive(io.NewSectionReader(archiveFile, 0, archiveInfo.Size()), kind)
+			if headErr == nil {
+				if si, siErr := ReadSiteInfo(head); siErr == nil {
+					lp = si.LinkParser()
+				}
+			}
+		}
+		namespaces, nsErr := ParseNamespaces(c.String("namespaces"), lp)
+		if nsErr != nil {
+			return NewUsageError("%v", nsErr)
+		}
+
+		// Anything but a multistream archive is read start to finish.
+		if kind != ArchiveMultistream && c.Bool("resume") {
+			return NewUsageError("--resume only works with multistream archives, this is %s", kind)
+		}
+
+		// Summaries are made from wikitext, in one go.
+		var summaries *summaryOutput
+		if c.Bool("summaries") {
+			if kind == ArchiveZim || kind == ArchiveHTMLDump {
+				return NewUsageError("--summaries needs a wikitext archive, this is %s", kind)
+			}
+			if c.Bool("resume") {
+				return NewUsageError("--summaries can't be used with --resume")
+			}
+			var createErr error
+			summaries, createErr = createSummaries(SummariesPath(c.String("wpindex")))
+			if createErr != nil {
+				return createErr
+			}
+			defer summaries.file.Close()
+		}
+
+		// Wikitext has the text and sentence of each link, unless they're not
+		// wanted. Pages in namespaces which aren't included are left out.
+		noContext := c.Bool("no-context")
+		stripArticle := func(a *Article) *StrippedArticle {
+			if !namespaces.Has(a.Namespace) && a.Namespace != NamespaceCategory {
+				return nil
+			}
+			sa := filterNamespace(lp.Strip(a, namespaces), namespaces)
+			if sa != nil && namespaces.Has(sa.Namespace) && sa.Redirect == "" {
+				summaries.Add(sa.ID, lp.LeadParagraph(a.Text))
+			}
+			if sa != nil && noContext {
+				sa.DropContext()
+			}
+			return sa
+		}
+
+		// ZIM files and HTML dumps hold rendered HTML rather than wikitext.
+		if kind == ArchiveZim {
+			return indexOnePass(c, format, func(visit func(*StrippedArticle) bool) error {
+				return LoadZim(zim, visit)
+			})
+		}
+		if kind == ArchiveHTMLDump {
+			return indexOnePass(c, format, func(visit func(*StrippedArticle) bool) error {
+				return LoadHTMLDump(archiveFile, namespaces, func(sa *StrippedArticle) bool {
+					sa = filterNamespace(sa, namespaces)
+					if sa != nil && noContext {
+						sa.DropContext()
+					}
+					return sa == nil || visit(sa)
+				})
+			})
+		}
+
+		if kind != ArchiveMultistream {
+			xml, openErr := OpenArchive(archiveFile, kind)
+			if openErr != nil {
+				return NewFileError("Could not read wiki archive '%s': %v", archivePath, openErr)
+			}
+			indexErr := indexOnePass(c, format, func(visit func(*StrippedArticle) bool) error {
+				return LoadWiki(xml, func(a *Article) bool {
+					sa := stripArticle(a)
+					return sa == nil || visit(sa)
+				})
+			})
+			if indexErr != nil {
+				return indexErr
+			}
+			return summaries.Finish()
+		}
 
+		// Multistream archives are read in parallel, using the index if
+		// there is one. The default index is only for the default archive,
+		// so another archive's streams are found by scanning it unless it's
+		// given an index of its own.
 		indexPath := c.String("wiki-index")
-		indexFile, indexErr := os.Open(indexPath)
-		if indexErr != nil {
-			return NewFileError("Could not open wiki index '%s'", indexPath)
+		var indexFile *os.File
+		indexErr := os.ErrNotExist
+		if c.IsSet("wiki-index") || !c.IsSet("wiki-archive") {
+			indexFile, indexErr = os.Open(indexPath)
+			if indexErr != nil && c.IsSet("wiki-index") {
+				return NewFileError("Could not open wiki index '%s'", indexPath)
+			}
 		}
 
+		// Open the temp output file, either fresh or from a checkpoint.
 		outPath := c.String("wpindex")
-		outFile, outErr := os.Create(outPath)
-		if outErr != nil {
-			return NewFileError("Could not open output file '%s'", outPath)
-		}
+		tmpPath := outPath + ".tmp"
+		checkpointPath := outPath + ".checkpoint"
 
-		tStart := time.Now()
+		checkpoint := &Checkpoint{Archive: archivePath, Format: format.String(), Ordered: c.Bool("ordered")}
+		var outFile *os.File
+		if c.Bool("resume") {
+			loaded, loadErr := LoadCheckpoint(checkpointPath)
+			if loadErr != nil {
+				return NewFileError("Could not read checkpoint '%s': %v", checkpointPath, loadErr)
+			}
+			if loaded.Archive != checkpoint.Archive || loaded.Format != checkpoint.Format || loaded.Ordered != checkpoint.Ordered {
+				return NewUsageError("Checkpoint '%s' is for archive '%s' in %s format (ordered: %v)", checkpointPath, loaded.Archive, loaded.Format, loaded.Ordered)
+			}
+			checkpoint = loaded
+
+			var outErr error
+			outFile, outErr = os.OpenFile(tmpPath, os.O_RDWR, 0644)
+			if outErr != nil {
+				return NewFileError("Could not open output file '%s'", tmpPath)
+			}
+			if err := outFile.Truncate(checkpoint.Size); err != nil {
+				return NewFileError("Could not truncate output file '%s': %v", tmpPath, err)
+			}
+			if _, err := outFile.Seek(checkpoint.Size, io.SeekStart); err != nil {
+				return NewFileError("Could not seek output file '%s': %v", tmpPath, err)
+			}
+			fmt.Printf("Resuming from %d articles in %d chunks.\n", checkpoint.Articles, len(checkpoint.Chunks))
+		} else {
+			var outErr error
+			outFile, outErr = os.Create(tmpPath)
+			if outErr != nil {
+				return NewFileError("Could not open output file '%s'", tmpPath)
+			}
+			if err := checkpoint.Save(checkpointPath); err != nil {
+				return NewFileError("Could not write checkpoint '%s': %v", checkpointPath, err)
+			}
+		}
+		defer outFile.Close()
 
-		// Set up wpindex writer, channel for articles
-		writer := NewWpindexWriter(outFile)
-		articles := make(chan *StrippedArticle, 512)
+		// Cancel the load on Ctrl-C, keeping the last checkpoint.
 		ec := NewErrorContext()
-		ec.Start()
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupts)
 		go func() {
-			n := 0
-			rate := NewRateMeasure(1)
-			for sa := range articles {
+			select {
+			case <-interrupts:
+				ec.Cancel(ErrInterrupted)
+			case <-ec.Canceled:
+			}
+		}()
+
+		tStart := time.Now()
+
+		writer := NewWpindexWriterFormat(outFile, format)
+		done := checkpoint.Done()
+		every := c.Int("checkpoint-every")
+
+		var pending []int64     // Chunks written since the last checkpoint.
+		var pendingArticles int // Articles written since the last checkpoint.
+		var writeErr error
+
+		save := func() error {
+			if err := writer.Checkpoint(); err != nil {
+				return err
+			}
+			if err := outFile.Sync(); err != nil {
+				return err
+			}
+			size, err := outFile.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+
+			checkpoint.Size = size
+			checkpoint.Articles += pendingArticles
+			checkpoint.Chunks = append(checkpoint.Chunks, pending...)
+			pending = pending[:0]
+			pendingArticles = 0
+			return checkpoint.Save(checkpointPath)
+		}
+
+		n := checkpoint.Articles
+		rate := NewRateMeasure(1)
+		opts := ChunkOptions{
+			Skip:    func(chunk Chunk) bool { return done[chunk.Start] },
+			Ordered: c.Bool("ordered"),
+		}
+		visitor := func(chunk Chunk, articles []*Article) bool {
+			for _, a := range articles {
+				sa := stripArticle(a)
+				if sa == nil {
+					continue
+				}
 				n++
 				rate.Count(1)
 				if n%500 == 0 {
 					PrintTicker("Saving wpindex...   ", fmt.Sprintf("[rate:%4.2f  id:%d  title:'%s']", rate.Average(), sa.ID, sa.Title))
 				}
-				writer.WriteArticle(sa) // write article to *.wpindex
+				writeErr = writer.WriteArticle(sa) // write article to *.wpindex
+				if writeErr != nil {
+					return false
+				}
+				pendingArticles++
 			}
-			rate.Stop()
 
-			closeErr := writer.Close()
-			if closeErr != nil {
-				ec.Cancel(closeErr)
+			pending = append(pending, chunk.Start)
+			if len(pending) >= every {
+				writeErr = save()
 			}
-			ec.Done()
-		}()
-
-		loadErr := LoadWikiCompressed(indexFile, archiveFile, func(a *Article) bool {
-			sa := NewStrippedArticle(a)
-			articles <- sa
-			return true
-		})
+			return writeErr == nil
+		}
 
-		if loadErr != nil {
-			return NewInternalError("failed to parse wiki archive")
+		var loadErr error
+		if indexErr == nil {
+			defer indexFile.Close()
+			loadErr = LoadWikiChunks(ec, indexFile, archiveFile, opts, visitor)
+		} else {
+			PrintTicker("Finding streams...  ", "")
+			streams, findErr := FindStreams(archiveFile, 0)
+			if findErr != nil {
+				return NewFileError("Could not read wiki archive '%s': %v", archivePath, findErr)
+			}
+			PrintTicker("Finding streams...  ", fmt.Sprintf("[found %d, no index]", len(streams)))
+			fmt.Println()
+			loadErr = LoadWikiStreams(ec, streams, archiveFile, opts, visitor)
 		}
+		rate.Stop()
 
-		close(articles)
+		if loadErr == ErrInterrupted {
+			fmt.Println()
+			return NewInterruptError("interrupted, run again with --resume to continue from %d articles", checkpoint.Articles)
+		} else if writeErr != nil {
+			return NewInternalError("failed to write to *.wpindex file: %v", writeErr.Error())
+		} else if loadErr != nil {
+			return NewInternalError("failed to parse wiki archive: %v", loadErr)
+		}
 
-		writerErr := ec.Wait()
-		if writerErr != nil {
-			return NewInternalError("failed to write to *.wpindex file: %v", writerErr.Error())
+		// Finish the file, and move it into place.
+		closeErr := writer.Close()
+		if closeErr == nil {
+			closeErr = outFile.Sync()
+		}
+		if closeErr == nil {
+			closeErr = outFile.Close()
 		}
+		if closeErr != nil {
+			return NewInternalError("failed to write to *.wpindex file: %v", closeErr.Error())
+		}
+		if err := os.Rename(tmpPath, outPath); err != nil {
+			return NewFileError("Could not move '%s' to '%s': %v", tmpPath, outPath, err)
+		}
+		os.Remove(checkpointPath)
 
 		dLoad := time.Since(tStart).Seconds()
 		PrintTicker("Saving wpindex...   ", fmt.Sprintf("[done in %4.2fs]", dLoad))
 		fmt.Println()
 
-		return nil
+		return summaries.Finish()
 	},
 }
+
+// summaryOutput writes the summaries file next to a `*.wpindex`, through a
+// temp file like the index itself. A nil summaryOutput writes nothing.
+type summaryOutput struct {
+	path   string
+	file   *os.File
+	writer *SummaryWriter
+	err    error // The first error writing a summary.
+}
+
+// createSummaries creates `<path>.tmp`, to write summaries to.
+func createSummaries(path string) (*summaryOutput, error) {
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, NewFileError("Could not open output file '%s.tmp'", path)
+	}
+	writer, err := NewSummaryWriter(file)
+	if err != nil {
+		file.Close()
+		return nil, NewFileError("Could not write to '%s.tmp': %v", path, err)
+	}
+	return &summaryOutput{path: path, file: file, writer: writer}, nil
+}
+
+// Add writes the summary of page `id`, keeping the first error for Finish.
+func (so *summaryOutput) Add(id int, summary string) {
+	if so == nil || so.err != nil || id == 0 {
+		return
+	}
+	so.err = so.writer.Write(id, summary)
+}
+
+// Finish writes the rest of the summaries file, and moves it into place.
+func (so *summaryOutput) Finish() error {
+	if so == nil {
+		return nil
+	}
+	err := so.err
+	if err == nil {
+		err = so.writer.Close()
+	}
+	if err == nil {
+		err = so.file.Sync()
+	}
+	if err == nil {
+		err = so.file.Close()
+	}
+	if err != nil {
+		return NewInternalError("failed to write summaries: %v", err)
+	}
+	if err := os.Rename(so.path+".tmp", so.path); err != nil {
+		return NewFileError("Could not move '%s.tmp' to '%s': %v", so.path, so.path, err)
+	}
+	fmt.Printf("Wrote %d summaries to '%s'.\n", so.writer.Len(), so.path)
+	return nil
+}
+
+// filterNamespace returns `sa` if it's in one of `namespaces`, or nil if it
+// isn't. Category pages are kept with only their own categories, so an
+// Index can put categories in their parents.
+func filterNamespace(sa *StrippedArticle, namespaces *NamespaceFilter) *StrippedArticle {
+	switch {
+	case namespaces.Has(sa.Namespace):
+		return sa
+	case sa.Namespace == NamespaceCategory && len(sa.Categories) > 0:
+		return &StrippedArticle{Title: sa.Title, ID: sa.ID, Namespace: sa.Namespace, Categories: sa.Categories}
+	default:
+		return nil
+	}
+}
+
+// indexOnePass writes a `*.wpindex` file from a source of articles which
+// can only be read start to finish, so there are no checkpoints to resume
+// from. `load` calls `visit` for each article, and stops if it returns false.
+func indexOnePass(c *cli.Context, format WpindexFormat, load func(visit func(*StrippedArticle) bool) error) error {
+	outPath := c.String("wpindex")
+	tmpPath := outPath + ".tmp"
+	outFile, outErr := os.Create(tmpPath)
+	if outErr != nil {
+		return NewFileError("Could not open output file '%s'", tmpPath)
+	}
+	defer outFile.Close()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+
+	tStart := time.Now()
+	writer := NewWpindexWriterFormat(outFile, format)
+
+	PrintTicker("Saving wpindex...   ", "")
+	n := 0
+	rate := NewRateMeasure(1)
+	var writeErr error
+	loadErr := load(func(sa *StrippedArticle) bool {
+		select {
+		case <-interrupts:
+			writeErr = ErrInterrupted
+			return false
+		default:
+		}
+
+		n++
+		rate.Count(1)
+		if n%500 == 0 {
+			PrintTicker("Saving wpindex...   ", fmt.Sprintf("[rate:%4.2f  id:%d  title:'%s']", rate.Average(), sa.ID, sa.Title))
+		}
+		writeErr = writer.WriteArticle(sa)
+		return writeErr == nil
+	})
+	rate.Stop()
+
+	if writeErr == ErrInterrupted {
+		fmt.Println()
+		outFile.Close()
+		os.Remove(tmpPath)
+		return NewInterruptError("interrupted")
+	} else if writeErr != nil {
+		return NewInternalError("failed to write to *.wpindex file: %v", writeErr.Error())
+	} else if loadErr != nil {
+		return NewInternalError("failed to read articles: %v", loadErr)
+	}
+
+	closeErr := writer.Close()
+	if closeErr == nil {
+		closeErr = outFile.Sync()
+	}
+	if closeErr == nil {
+		closeErr = outFile.Close()
+	}
+	if closeErr != nil {
+		return NewInternalError("failed to write to *.wpindex file: %v", closeErr.Error())
+	}
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		return NewFileError("Could not move '%s' to '%s': %v", tmpPath, outPath, err)
+	}
+
+	dLoad := time.Since(tStart).Seconds()
+	PrintTicker("Saving wpindex...   ", fmt.Sprintf("[done in %4.2fs, %d articles]", dLoad, n))
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+
+	. "github.com/wgoodall01/wikipath/wp"
+)
+
+// IndexDiffCmd is the CLI command to compare two `*.wpindex` files.
+var IndexDiffCmd = cli.Command{
+	Name:      "index-diff",
+	Usage:     "Show the changes between two *.wpindex files.",
+	ArgsUsage: "OLD.wpindex NEW.wpindex",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "Output format, 'text' or 'jsonl'",
+			Value: "text",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		args := c.Args()
+		if len(args) != 2 {
+			return NewUsageError("Expected 2 *.wpindex files. Got %d", len(args))
+		}
+
+		format := c.String("format")
+		if format != "text" && format != "jsonl" {
+			return NewUsageError("Unknown output format '%s'", format)
+		}
+
+		// The old file may be read more than once, if either isn't sorted.
+		var files []*os.File
+		defer func() {
+			for _, file := range files {
+				file.Close()
+			}
+		}()
+		var openers [2]func() (*WpindexReader, error)
+		for i, path := range args {
+			path := path
+			openers[i] = func() (*WpindexReader, error) {
+				file, fileErr := os.Open(path)
+				if fileErr != nil {
+					return nil, fmt.Errorf("could not open index file '%s'", path)
+				}
+				files = append(files, file)
+
+				reader, readerErr := NewWpindexReader(file)
+				if readerErr != nil {
+					return nil, fmt.Errorf("could not understand index '%s'", path)
+				}
+				return reader, nil
+			}
+		}
+
+		out := bufio.NewWriter(os.Stdout)
+		defer out.Flush()
+		encoder := json.NewEncoder(out)
+
+		summary, diffErr := DiffWpindex(openers[0], openers[1], func(ad *ArticleDiff) bool {
+			if format == "jsonl" {
+				encoder.Encode(ad)
+			} else {
+				printArticleDiff(out, ad)
+			}
+			return true
+		})
+		if diffErr != nil {
+			return NewFileError("Error reading .wpindex files: %v", diffErr)
+		}
+
+		if format == "text" {
+			fmt.Fprintln(out)
+			fmt.Fprintf(out, "Articles  : %d -> %d\n", summary.Old, summary.New)
+			fmt.Fprintf(out, "Added     : %d\n", summary.Added)
+			fmt.Fprintf(out, "Removed   : %d\n", summary.Removed)
+			fmt.Fprintf(out, "Renamed   : %d\n", summary.Renamed)
+			fmt.Fprintf(out, "Redirects : %d changed\n", summary.Redirects)
+			fmt.Fprintf(out, "Links     : %d articles changed, +%d -%d\n", summary.LinksChanged, summary.LinksAdded, summary.LinksRemoved)
+			if summary.Duplicates > 0 {
+				fmt.Fprintf(out, "Duplicates: %d repeated IDs or titles\n", summary.Duplicates)
+			}
+		}
+
+		return nil
+	},
+}
+
+// printArticleDiff prints an ArticleDiff in the text format. Added,
+// removed and duplicate articles are one line each; for changed ones every
+// change follows on an indented line.
+func printArticleDiff(out *bufio.Writer, ad *ArticleDiff) {
+	switch ad.Kind {
+	case DiffAdded:
+		fmt.Fprintf(out, "+ [%d] %s", ad.ID, ad.Title)
+	case DiffRemoved:
+		fmt.Fprintf(out, "- [%d] %s", ad.ID, ad.Title)
+	case DiffDuplicate:
+		fmt.Fprintf(out, "! [%d] %s (duplicate in %s file)\n", ad.ID, ad.Title, ad.File)
+		return
+	default:
+		fmt.Fprintf(out, "~ [%d] %s", ad.ID, ad.Title)
+	}
+
+	if ad.Kind != DiffChanged {
+		if ad.Redirect != "" {
+			fmt.Fprintf(out, " => %s", ad.Redirect)
+		} else {
+			fmt.Fprintf(out, " (%d links)", len(ad.AddedLinks)+len(ad.RemovedLinks))
+		}
+		fmt.Fprintln(out)
+		return
+	}
+	fmt.Fprintln(out)
+
+	if ad.Renamed() {
+		fmt.Fprintf(out, "    renamed from '%s'\n", ad.OldTitle)
+	}
+	if ad.RedirectChanged {
+		fmt.Fprintf(out, "    redirect '%s' => '%s'\n", ad.OldRedirect, ad.Redirect)
+	}
+	for _, l := range ad.AddedLinks {
+		fmt.Fprintf(out, "    + %s\n", l)
+	}
+	for _, l := range ad.RemovedLinks {
+		fmt.Fprintf(out, "    - %s\n", l)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+
+	. "github.com/wgoodall01/wikipath/wp"
+)
+
+// IndexExtractCmd is the CLI command to cut a smaller `*.wpindex` file out
+// of a bigger one.
+var IndexExtractCmd = cli.Command{
+	Name:      "index-extract",
+	Usage:     "Write a smaller *.wpindex with the articles around some seeds.",
+	ArgsUsage: "[SEED TITLE...]",
+	Flags: []cli.Flag{
+		WpFlags.WpindexPath,
+		WpFlags.WpindexFormat,
+		cli.StringFlag{
+			Name:  "out, o",
+			Usage: "Path to write the extracted *.wpindex to",
+		},
+		cli.IntFlag{
+			Name:  "radius, r",
+			Usage: "Include articles up to this many links from the seeds",
+			Value: 1,
+		},
+		cli.StringSliceFlag{
+			Name:  "category, c",
+			Usage: "Add the pages in this category to the seeds",
+		},
+		cli.IntFlag{
+			Name:  "random",
+			Usage: "Add this many randomly chosen articles to the seeds",
+		},
+		cli.Int64Flag{
+			Name:  "seed",
+			Usage: "Random seed for --random, for a repeatable sample",
+			Value: 1,
+		},
+	},
+	Action: func(c *cli.Context) error {
+		format, formatErr := ParseWpindexFormat(c.String("format"))
+		if formatErr != nil {
+			return NewUsageError("%v", formatErr)
+		}
+
+		outPath := c.String("out")
+		if outPath == "" {
+			return NewUsageError("An output file is required, with --out")
+		}
+		if len(c.Args()) == 0 && len(c.StringSlice("category")) == 0 && c.Int("random") == 0 {
+			return NewUsageError("Pass some seed titles, --category or --random")
+		}
+
+		indexPath := c.String("wpindex")
+		ind, loadErr := LoadIndex(indexPath, AllNamespaces, false)
+		if loadErr != nil {
+			return loadErr
+		}
+
+		// Pick the seeds, and everything around them.
+		var seeds []*IndexItem
+		for _, title := range c.Args() {
+			item := ind.Get(title)
+			if item == nil {
+				return NewUsageError("Can't find article '%s'", title)
+			}
+			seeds = append(seeds, item)
+		}
+		for _, category := range c.StringSlice("category") {
+			members := ind.CategorySeeds(category)
+			if len(members) == 0 {
+				return NewUsageError("Can't find any pages in category '%s'", category)
+			}
+			seeds = append(seeds, members...)
+		}
+		if n := c.Int("random"); n > 0 {
+			rng := rand.New(rand.NewSource(c.Int64("seed")))
+			seeds = append(seeds, ind.Sample(n, rng)...)
+		}
+
+		keep := ind.Ball(seeds, c.Int("radius"))
+		fmt.Printf("Extracting %d articles around %d seeds...\n", len(keep), len(seeds))
+
+		// Copy them over.
+		indexFile, indexErr := os.Open(indexPath)
+		if indexErr != nil {
+			return NewFileError("Could not open index file '%s'", indexPath)
+		}
+		defer indexFile.Close()
+
+		reader, readerErr := NewWpindexReader(indexFile)
+		if readerErr != nil {
+			return NewFileError("Could not understand index.")
+		}
+
+		outFile, outErr := os.Create(outPath)
+		if outErr != nil {
+			return NewFileError("Could not open output file '%s'", outPath)
+		}
+		defer outFile.Close()
+
+		tStart := time.Now()
+		writer := NewWpindexWriterFormat(outFile, format)
+		n, extractErr := ExtractWpindex(ind, keep, reader, writer)
+		if extractErr != nil {
+			return NewInternalError("failed to extract articles: %v", extractErr)
+		}
+		if closeErr := writer.Close(); closeErr != nil {
+			return NewInternalError("failed to write to *.wpindex file: %v", closeErr)
+		}
+
+		fmt.Printf("Wrote %d articles and redirects to '%s' in %4.2fs\n", n, outPath, time.Since(tStart).Seconds())
+		return nil
+	},
+}
@@ -36,15 +36,24 @@ var IndexShowCmd = cli.Command{
 		}
 
 		for {
-			sa, _ := reader.ReadArticle()
-			if sa == nil {
+			sa, readErr := reader.ReadArticle()
+			if readErr == EOF {
+				fmt.Printf("No article '%s' in the index.\n", c.Args()[0])
 				break
+			} else if readErr != nil {
+				return NewFileError("Could not read index: %v\n(run 'wikipath index-verify' for details)", readErr)
 			}
 			if NormalizeArticleTitle(sa.Title) == NormalizeArticleTitle(c.Args()[0]) {
 				fmt.Println(sa.Title)
 				for _, l := range sa.Links {
 					fmt.Println("  " + l)
 				}
+				for _, cat := range sa.Categories {
+					fmt.Println("  [" + cat + "]")
+				}
+				for _, iw := range sa.Interwiki {
+					fmt.Println("  <" + iw.String() + ">")
+				}
 				break
 			}
 
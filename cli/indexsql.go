@@ -0,0 +1,92 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	. "github.com/wgoodall01/wikipath/wp"
+)
+
+// sqlFlags select SQL table dumps as the source for `wikipath index`.
+var sqlFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "sql-page",
+		Usage: "Build from SQL dumps instead of the XML archive, starting with this *-page.sql(.gz)",
+	},
+	cli.StringFlag{
+		Name:  "sql-pagelinks",
+		Usage: "SQL dump of the pagelinks table, needed with --sql-page",
+	},
+	cli.StringFlag{
+		Name:  "sql-redirect",
+		Usage: "SQL dump of the redirect table, to include redirects",
+	},
+	cli.StringFlag{
+		Name:  "sql-linktarget",
+		Usage: "SQL dump of the linktarget table, for pagelinks dumps with pl_target_id",
+	},
+}
+
+// openSQLDump opens a SQL dump, decompressing it if it's gzipped.
+func openSQLDump(path string, closers *[]io.Closer) (io.Reader, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, NewFileError("Could not open SQL dump '%s'", path)
+	}
+	*closers = append(*closers, file)
+
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, NewFileError("Could not decompress SQL dump '%s': %v", path, err)
+	}
+	*closers = append(*closers, gz)
+	return gz, nil
+}
+
+// indexFromSQL builds a `*.wpindex` file from SQL table dumps. It reads
+// them in one pass, so there are no checkpoints to resume from.
+func indexFromSQL(c *cli.Context, format WpindexFormat) error {
+	if c.String("sql-pagelinks") == "" {
+		return NewUsageError("--sql-page needs --sql-pagelinks too")
+	}
+	if c.Bool("resume") {
+		return NewUsageError("--resume doesn't work with --sql-page")
+	}
+
+	var closers []io.Closer
+	defer func() {
+		for _, cl := range closers {
+			cl.Close()
+		}
+	}()
+
+	var dumps SQLDumps
+	var err error
+	if dumps.Page, err = openSQLDump(c.String("sql-page"), &closers); err != nil {
+		return err
+	}
+	if dumps.PageLinks, err = openSQLDump(c.String("sql-pagelinks"), &closers); err != nil {
+		return err
+	}
+	if dumps.Redirect, err = openSQLDump(c.String("sql-redirect"), &closers); err != nil {
+		return err
+	}
+	if dumps.LinkTarget, err = openSQLDump(c.String("sql-linktarget"), &closers); err != nil {
+		return err
+	}
+
+	return indexOnePass(c, format, func(visit func(*StrippedArticle) bool) error {
+		return ImportSQLDumps(dumps, visit)
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+
+	. "github.com/wgoodall01/wikipath/wp"
+)
+
+// IndexVerifyCmd is the CLI command to check a `*.wpindex` file for damage.
+var IndexVerifyCmd = cli.Command{
+	Name:  "index-verify",
+	Usage: "Check a *.wpindex file for corruption and inconsistencies.",
+	Flags: []cli.Flag{
+		WpFlags.WpindexPath,
+		cli.IntFlag{
+			Name:  "max-issues",
+			Usage: "Print at most this many issues of each kind, -1 for all",
+			Value: 20,
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print issues as JSON lines",
+		},
+		cli.BoolFlag{
+			Name:  "strict",
+			Usage: "Fail on semantic issues, not just structural ones",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		indexPath := c.String("wpindex")
+		indexFile, indexErr := os.Open(indexPath)
+		if indexErr != nil {
+			return NewFileError("could not open index file '%s'", indexPath)
+		}
+		defer indexFile.Close()
+
+		maxIssues := c.Int("max-issues")
+		printed := make(map[IssueKind]int)
+		encoder := json.NewEncoder(os.Stdout)
+
+		tStart := time.Now()
+		report, verifyErr := VerifyWpindex(indexFile, func(vi *VerifyIssue) {
+			if maxIssues >= 0 && printed[vi.Kind] >= maxIssues {
+				return
+			}
+			printed[vi.Kind]++
+
+			if c.Bool("json") {
+				encoder.Encode(vi)
+			} else {
+				fmt.Println(vi)
+			}
+		})
+		if verifyErr != nil {
+			return NewFileError("could not read '%s' as a .wpindex file: %v", indexPath, verifyErr)
+		}
+
+		if c.Bool("json") {
+			return verifyResult(c, report)
+		}
+
+		fmt.Println()
+		fmt.Printf("Format    : %s\n", report.Format)
+		fmt.Printf("Segments  : %d\n", report.Segments)
+		fmt.Printf("Records   : %d (%d articles, %d redirects)\n", report.Records, report.Articles, report.Redirects)
+		fmt.Printf("Links     : %d\n", report.Links)
+		if report.Format == FormatCompact {
+			fmt.Printf("Blocks    : %d\n", report.Blocks)
+			fmt.Printf("Footer    : %v\n", report.Footer)
+		}
+		for _, kind := range []IssueKind{
+			IssueChecksum, IssueCorrupt, IssueTruncated, IssueCount,
+			IssueDuplicateTitle, IssueEmptyTitle, IssueRedirectLinks, IssueDanglingLink,
+		} {
+			if n := report.Issues[kind]; n > 0 {
+				fmt.Printf("%-16s: %d\n", kind, n)
+			}
+		}
+		fmt.Printf("\nVerified in %4.2fs\n", time.Since(tStart).Seconds())
+
+		return verifyResult(c, report)
+	},
+}
+
+// verifyResult turns a VerifyReport into the command's exit status.
+func verifyResult(c *cli.Context, report *VerifyReport) error {
+	if !report.OK() {
+		return NewFileError("'%s' is damaged", c.String("wpindex"))
+	}
+	if c.Bool("strict") {
+		for kind, n := range report.Issues {
+			if n > 0 {
+				return NewFileError("'%s' has %d %s issues", c.String("wpindex"), n, kind)
+			}
+		}
+	}
+	return nil
+}
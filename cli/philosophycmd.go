@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	. "github.com/wgoodall01/wikipath/wp"
+)
+
+// PhilosophyCmd is the CLI command to play "Getting to Philosophy": follow
+// the first link of each article until it gets to Philosophy.
+var PhilosophyCmd = cli.Command{
+	Name:      "philosophy",
+	Usage:     "Follow the first link of each article from TITLE, until it gets to Philosophy.",
+	ArgsUsage: "TITLE",
+	Flags: []cli.Flag{
+		WpFlags.WpindexPath,
+		WpFlags.Namespaces,
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "Article to stop at",
+			Value: "Philosophy",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		title := strings.Join(c.Args(), " ")
+		if title == "" {
+			return NewUsageError("An article title is required")
+		}
+
+		namespaces, nsErr := ParseNamespaces(c.String("namespaces"), DefaultLinkParser)
+		if nsErr != nil {
+			return NewUsageError("%v", nsErr)
+		}
+
+		ind, loadErr := LoadIndex(c.String("wpindex"), namespaces, false)
+		if loadErr != nil {
+			return loadErr
+		}
+
+		item := ind.Get(title)
+		if item == nil {
+			return NewUsageError("Can't find article '%s'", title)
+		}
+		target := ind.Get(c.String("to"))
+
+		// Stop at the target, even if the chain goes on past it.
+		chain, loop := ind.FollowFirstLink(item)
+		reached := false
+		for i, it := range chain {
+			if it == target {
+				chain, loop, reached = chain[:i+1], -1, true
+				break
+			}
+		}
+
+		fmt.Println()
+		for i, it := range chain {
+			switch {
+			case i == 0:
+				fmt.Printf("   %s\n", it.Title)
+			case i == loop:
+				fmt.Printf("-> %s  [loop starts here]\n", it.Title)
+			default:
+				fmt.Printf(" > %s\n", it.Title)
+			}
+		}
+
+		last := chain[len(chain)-1]
+		switch {
+		case reached:
+			fmt.Printf("\nGot to '%s' in %d links.\n", last.Title, len(chain)-1)
+		case loop == -1:
+			fmt.Printf("\nStuck at '%s', which has no first link.\n", last.Title)
+		default:
+			fmt.Printf("\nStuck in a loop of %d articles: '%s' links back to '%s'.\n", len(chain)-loop, last.Title, chain[loop].Title)
+		}
+		return nil
+	},
+}
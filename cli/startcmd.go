@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli"
@@ -15,82 +17,88 @@ import (
 var StartCmd = cli.Command{
 	Name:  "start",
 	Usage: "Start interactive mode",
-	Flags: []cli.Flag{WpFlags.WpindexPath},
+	Flags: []cli.Flag{
+		WpFlags.WpindexPath,
+		WpFlags.Namespaces,
+		cli.StringFlag{
+			Name:  "edges",
+			Usage: "Types of link to follow, like 'prose,see-also' or '-infobox,-navbox'",
+			Value: "all",
+		},
+		cli.BoolFlag{
+			Name:  "categories",
+			Usage: "Let paths go from an article to a category it's in, and on to another article in it",
+		},
+		cli.StringFlag{
+			Name:  "disambig",
+			Usage: "How paths treat disambiguation pages: 'follow', 'avoid', or 'free' to search through them first, as if linking to one cost nothing",
+			Value: "follow",
+		},
+		cli.BoolFlag{
+			Name:  "summaries",
+			Usage: "Print the lead paragraph of each article in a path, from <wpindex>.summaries",
+		},
+		cli.StringSliceFlag{
+			Name:  "wiki",
+			Usage: "Another language's *.wpindex to load, like 'de=./wikis/dewiki.wpindex'. Can be given more than once",
+		},
+		cli.StringFlag{
+			Name:  "language, lang",
+			Usage: "Language code of the --wpindex wiki, when there are others",
+			Value: "en",
+		},
+		cli.StringFlag{
+			Name:  "sitelinks",
+			Usage: "Wikidata sitelinks joining the languages: a wb_items_per_site SQL dump (*.sql, *.sql.gz) or JSON",
+		},
+	},
 	Action: func(c *cli.Context) error {
-		// Open the index
-		indexPath := c.String("wpindex")
-		indexFile, indexErr := os.Open(indexPath)
-		if indexErr != nil {
-			return NewFileError("Could not open index file '%s'", indexPath)
-		}
-
-		// Create WpindexReader
-		reader, readerErr := NewWpindexReader(indexFile)
-		if readerErr != nil {
-			return NewFileError("Could not understand index.")
-		}
-
-		PrintTicker("Loading wpindex...  ", "")
-
-		// Load all the articles.
-		tLoad := time.Now()
-		ind := NewIndex()
-
-		articles := make(chan *StrippedArticle, 512)
-		ec := NewErrorContext()
-		ec.Start()
-		go func() {
-			n := 0
-			rate := NewRateMeasure(0.5)
-			for sa := range articles {
-				n++
-				rate.Count(1)
-				if n%500 == 0 {
-					PrintTicker("Loading wpindex...  ", fmt.Sprintf("[rate:%4.2f  article:%d  title: %s]", rate.Average(), sa.ID, sa.Title))
-				}
-				ind.AddArticle(sa)
-			}
-			rate.Stop()
-			ec.Done()
-		}()
-
-		var wpindexErr error = nil
-		for {
-			var sa *StrippedArticle
-			sa, wpindexErr = reader.ReadArticle()
-			if wpindexErr != nil {
-				break
-			}
-			articles <- sa
+		edges, edgesErr := ParseEdgeTypes(c.String("edges"))
+		if edgesErr != nil {
+			return NewUsageError("%v", edgesErr)
 		}
-		close(articles)
-
-		ec.Wait()
-
-		if wpindexErr != nil && wpindexErr != EOF {
-			return cli.NewExitError("Error loading .wpindex file: "+wpindexErr.Error(), 2)
+		disambig, disambigErr := ParseDisambigMode(c.String("disambig"))
+		if disambigErr != nil {
+			return NewUsageError("%v", disambigErr)
 		}
+		opts := PathOptions{Edges: edges, Categories: c.Bool("categories"), Disambiguation: disambig}
 
-		closeErr := reader.Close()
-		if closeErr != nil {
-			return cli.NewExitError("Couldn't close .wpindex reader", 3)
+		namespaces, nsErr := ParseNamespaces(c.String("namespaces"), DefaultLinkParser)
+		if nsErr != nil {
+			return NewUsageError("%v", nsErr)
 		}
 
-		dLoad := time.Since(tLoad).Seconds()
-		PrintTicker("Loading wpindex...  ", fmt.Sprintf("[done in %4.2fs]", dLoad))
-		fmt.Println()
+		ind, loadErr := LoadIndex(c.String("wpindex"), namespaces, true)
+		if loadErr != nil {
+			return loadErr
+		}
 
-		// Index all the articles.
-		fmt.Print("Making index...     ")
-		tBuild := time.Now()
-		ind.Build()
-		dBuild := time.Since(tBuild).Seconds()
-		fmt.Printf("[done in %4.2fs]\n", dBuild)
+		// Other languages' wikis are joined to it by sitelinks, with titles
+		// like "de:Berlin".
+		mi := NewMultiIndex()
+		if len(c.StringSlice("wiki")) == 0 {
+			if c.String("sitelinks") != "" {
+				return NewUsageError("--sitelinks needs other languages' indexes, with --wiki")
+			}
+			mi.Add("", ind)
+		} else {
+			mi.Add(c.String("language"), ind)
+			if err := loadWikis(mi, c.StringSlice("wiki"), namespaces); err != nil {
+				return err
+			}
+			if err := loadSitelinks(mi, c.String("sitelinks")); err != nil {
+				return err
+			}
+		}
 
-		// Run a GC
-		fmt.Print("Running GC...       ")
-		runtime.GC()
-		fmt.Printf("[done]\n")
+		var summaries *Summaries
+		if c.Bool("summaries") {
+			var summariesErr error
+			summaries, summariesErr = openSummaries(SummariesPath(c.String("wpindex")))
+			if summariesErr != nil {
+				return summariesErr
+			}
+		}
 
 		// Find a path.
 	InputLoop:
@@ -103,21 +111,22 @@ var StartCmd = cli.Command{
 			names[1] = Prompt("Second Article")
 
 			for i := range names {
-				items[i] = ind.Get(names[i])
+				items[i] = findArticle(mi, names[i])
 				if items[i] == nil {
 					fmt.Printf("Error: Can't find article '%s'", names[i])
 					continue InputLoop
 				}
+				items[i] = chooseArticle(mi.Index(items[i].Language), items[i])
 			}
 
 			fmt.Println()
-			fmt.Printf("%20s  -> %8d\n", items[0].Title, len(items[0].Forward))
-			fmt.Printf("%20s  <- %8d\n", items[1].Title, len(items[1].Reverse))
+			fmt.Printf("%20s  -> %8d\n", items[0].FullTitle(), len(items[0].Forward))
+			fmt.Printf("%20s  <- %8d\n", items[1].FullTitle(), len(items[1].Reverse))
 
 			tSearch := time.Now()
 			fmt.Printf("\nSearching for path... ")
 			nSteps := 10
-			path, touched := ind.FindPath(items[0], items[1], nSteps)
+			path, touched := mi.FindPathWith(items[0], items[1], nSteps, opts)
 			dSearch := time.Since(tSearch).Seconds()
 			fmt.Printf("[searched %d articles in %4.2fs]\n", touched, dSearch)
 
@@ -125,6 +134,8 @@ var StartCmd = cli.Command{
 				fmt.Printf("No paths found in %d steps.", nSteps)
 			} else {
 				fmt.Println("Path: ", path)
+				printHops(path)
+				printSummaries(path, summaries, ind.Language())
 			}
 
 			fmt.Println()
@@ -133,3 +144,142 @@ var StartCmd = cli.Command{
 		return nil
 	},
 }
+
+// findArticle gets an article by its title, or by its page ID if the name
+// is like "#1234". Either can have a language before it, like "de:#1234".
+func findArticle(mi *MultiIndex, name string) *IndexItem {
+	if item := mi.GetByID(name); item != nil {
+		return item
+	}
+	return mi.Get(name)
+}
+
+// loadWikis loads the indexes of other languages' wikis into `mi`, from a
+// list like "de=./wikis/dewiki.wpindex".
+func loadWikis(mi *MultiIndex, wikis []string, namespaces *NamespaceFilter) error {
+	for _, wiki := range wikis {
+		eq := strings.IndexByte(wiki, '=')
+		if eq <= 0 {
+			return NewUsageError("--wiki '%s' should be like 'de=./wikis/dewiki.wpindex'", wiki)
+		}
+		lang := strings.ToLower(strings.TrimSpace(wiki[:eq]))
+		if mi.Index(lang) != nil {
+			return NewUsageError("--wiki '%s': there's already an index for '%s'", wiki, lang)
+		}
+
+		fmt.Printf("\nLoading '%s' wiki:\n", lang)
+		ind, err := LoadIndex(wiki[eq+1:], namespaces, true)
+		if err != nil {
+			return err
+		}
+		mi.Add(lang, ind)
+	}
+	return nil
+}
+
+// loadSitelinks adds the sitelinks in a `wb_items_per_site` SQL dump or a
+// JSON file to `mi`, if there's a path.
+func loadSitelinks(mi *MultiIndex, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	var closers []io.Closer
+	defer func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}()
+	r, err := openSQLDump(path, &closers)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print("Loading sitelinks...")
+	var sl Sitelinks
+	if strings.HasSuffix(strings.TrimSuffix(path, ".gz"), ".json") {
+		sl, err = ReadSitelinksJSON(r, mi.Sites())
+	} else {
+		sl, err = ReadSitelinksSQL(r, mi.Sites())
+	}
+	if err != nil {
+		return NewFileError("Could not read sitelinks '%s': %v", path, err)
+	}
+	fmt.Printf(" [joined %d pages]\n", mi.AddSitelinks(sl))
+	return nil
+}
+
+// chooseArticle asks which article is meant when `item` is a disambiguation
+// page, returning the page itself if none is picked.
+func chooseArticle(ind *Index, item *IndexItem) *IndexItem {
+	choices := ind.Choices(item)
+	if len(choices) == 0 {
+		return item
+	}
+
+	fmt.Printf("\n'%s' may refer to:\n", item.Title)
+	for i, choice := range choices {
+		fmt.Printf("  %3d. %s\n", i+1, choice.Title)
+	}
+	n, err := strconv.Atoi(Prompt("Choice [none]"))
+	if err != nil || n < 1 || n > len(choices) {
+		return item
+	}
+	return choices[n-1]
+}
+
+// openSummaries opens a summaries file, which is read from as summaries
+// are needed, so it stays open.
+func openSummaries(path string) (*Summaries, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, NewFileError("Could not open summaries '%s', build them with 'wikipath index --summaries'", path)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, NewFileError("Could not open summaries '%s'", path)
+	}
+	summaries, err := OpenSummaries(file, info.Size())
+	if err != nil {
+		return nil, NewFileError("Could not read summaries '%s': %v", path, err)
+	}
+	return summaries, nil
+}
+
+// printSummaries prints the summary of each article in a path, if there
+// are summaries. They're only of the wiki in language `lang`.
+func printSummaries(path *IndexPath, summaries *Summaries, lang string) {
+	if summaries == nil {
+		return
+	}
+	for _, item := range path.ToSlice() {
+		if item.Language != lang {
+			continue
+		}
+		summary, err := summaries.Get(item.ID)
+		if err == ErrNoSummary {
+			summary = "(no summary)"
+		} else if err != nil {
+			summary = fmt.Sprintf("(couldn't read summary: %v)", err)
+		}
+		fmt.Printf("\n  %s\n    %s\n", item.FullTitle(), summary)
+	}
+}
+
+// printHops prints where each article in a path mentions the next one.
+func printHops(path *IndexPath) {
+	for _, hop := range path.Hops() {
+		if hop.Context == nil || hop.Context.Sentence == "" {
+			continue
+		}
+		from, to := hop.From.FullTitle(), hop.To.FullTitle()
+		if hop.Context.Section != "" {
+			from += "#" + hop.Context.Section
+		}
+		if hop.Context.Fragment != "" {
+			to += "#" + hop.Context.Fragment
+		}
+		fmt.Printf("\n  %s -> %s (\"%s\")\n", from, to, hop.Context.Anchor)
+		fmt.Printf("    %s\n", hop.Context.Sentence)
+	}
+}
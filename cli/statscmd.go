@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	. "github.com/wgoodall01/wikipath/wp"
+)
+
+// maxTop is the most of the most linked articles stats lists, like the
+// web API's MAX_TOP.
+const maxTop = 100
+
+// StatsCmd is the CLI command to describe the articles and links in an index.
+var StatsCmd = cli.Command{
+	Name:  "stats",
+	Usage: "Count the articles and links in an index, and how links are spread.",
+	Flags: []cli.Flag{
+		WpFlags.WpindexPath,
+		WpFlags.Namespaces,
+		cli.IntFlag{
+			Name:  "top",
+			Usage: fmt.Sprintf("How many of the most linked articles to list, up to %d", maxTop),
+			Value: 10,
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the stats as JSON",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		namespaces, nsErr := ParseNamespaces(c.String("namespaces"), DefaultLinkParser)
+		if nsErr != nil {
+			return NewUsageError("%v", nsErr)
+		}
+		top := c.Int("top")
+		if top < 0 || top > maxTop {
+			return NewUsageError("--top must be a number from 0 to %d", maxTop)
+		}
+
+		// Keep stdout for the JSON.
+		if c.Bool("json") {
+			Progress = os.Stderr
+		}
+
+		ind, loadErr := LoadIndex(c.String("wpindex"), namespaces, false)
+		if loadErr != nil {
+			return loadErr
+		}
+		st := ind.Stats(top)
+
+		if c.Bool("json") {
+			bytes, jsonErr := json.MarshalIndent(st, "", "  ")
+			if jsonErr != nil {
+				return NewInternalError("could not encode stats: %v", jsonErr)
+			}
+			fmt.Println(string(bytes))
+			return nil
+		}
+
+		fmt.Println()
+		fmt.Printf("Articles        : %d\n", st.Articles)
+		fmt.Printf("Redirects       : %d (%d broken)\n", st.Redirects, st.BrokenRedirects)
+		fmt.Printf("Edges           : %d\n", st.Edges)
+		fmt.Printf("Red links       : %d\n", st.RedLinks)
+		fmt.Printf("Duplicate links : %d\n", st.DuplicateLinks)
+		fmt.Printf("Self links      : %d\n", st.SelfLinks)
+		fmt.Printf("Orphans         : %d\n", st.Orphans)
+		fmt.Printf("Dead ends       : %d\n", st.DeadEnds)
+		printDegrees("In degree", st.InDegree)
+		printDegrees("Out degree", st.OutDegree)
+
+		if len(st.MostLinked) > 0 {
+			fmt.Println("\nMost linked:")
+			for i, li := range st.MostLinked {
+				fmt.Printf("  %3d. %-40s %8d\n", i+1, li.Title, li.Links)
+			}
+		}
+		return nil
+	},
+}
+
+// printDegrees prints the spread of articles' in or out degrees.
+func printDegrees(label string, ds DegreeStats) {
+	fmt.Printf("\n%-16s: mean %.2f, median %d, p90 %d, p99 %d, max %d\n", label, ds.Mean, ds.Median, ds.P90, ds.P99, ds.Max)
+	for bucket, n := range ds.Histogram {
+		lo, hi := 0, 0
+		if bucket > 0 {
+			lo, hi = 1<<(bucket-1), 1<<bucket-1
+		}
+		span := fmt.Sprintf("%d-%d", lo, hi)
+		if lo == hi {
+			span = fmt.Sprint(lo)
+		}
+		fmt.Printf("  %12s  %8d  %s\n", span, n, strings.Repeat("#", histogramBar(n, ds.Histogram)))
+	}
+}
+
+// histogramBar gets how long a bar of a histogram is, with the biggest 40
+// wide.
+func histogramBar(n int, histogram []int) int {
+	most := 0
+	for _, m := range histogram {
+		if m > most {
+			most = m
+		}
+	}
+	if most == 0 {
+		return 0
+	}
+	return (n*40 + most - 1) / most
+}
@@ -1,5 +1,7 @@
 module github.com/wgoodall01/wikipath
 
+go 1.19
+
 require (
 	github.com/etcd-io/bbolt v1.3.0
 	github.com/pkg/errors v0.8.0
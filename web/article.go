@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	wp "github.com/wgoodall01/wikipath/wp"
+	"log"
+	"net/http"
+	"time"
+)
+
+type ArticleInfo struct {
+	Title          string   `json:"title"`                 // Title of the article.
+	ID             int      `json:"id"`                    // Page ID, 0 if unknown.
+	RevisionID     int      `json:"revision_id,omitempty"` // ID of the revision indexed.
+	Timestamp      string   `json:"timestamp,omitempty"`   // When the revision was made.
+	Size           int      `json:"size,omitempty"`        // Length of the wikitext in bytes.
+	Links          int      `json:"links"`                 // How many links the article has.
+	Description    string   `json:"description,omitempty"` // Short description.
+	Disambiguation bool     `json:"disambiguation"`        // If it's a disambiguation page.
+	Interwiki      []string `json:"interwiki,omitempty"`   // Links to other wikis, like "fr:Paris".
+}
+
+func NewArticleInfo(item *wp.IndexItem) *ArticleInfo {
+	info := &ArticleInfo{
+		Title:          item.Title,
+		ID:             item.ID,
+		RevisionID:     item.RevisionID,
+		Size:           item.Size,
+		Links:          item.LinkCount,
+		Description:    item.Description,
+		Disambiguation: item.Disambiguation,
+	}
+	for _, iw := range item.Interwiki {
+		info.Interwiki = append(info.Interwiki, iw.String())
+	}
+	if !item.Timestamp.IsZero() {
+		info.Timestamp = item.Timestamp.Format(time.RFC3339)
+	}
+	return info
+}
+
+type ArticleHandler struct {
+	ind *wp.Index
+}
+
+func NewArticleHandler(ind *wp.Index) *ArticleHandler {
+	return &ArticleHandler{
+		ind: ind,
+	}
+}
+
+func (ah *ArticleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	title, id := query.Get("title"), query.Get("id")
+	if title == "" && id == "" {
+		NewHttpError(http.StatusBadRequest, "'title' or 'id' query parameter required").Send(w)
+		return
+	}
+
+	item, lookupErr := lookupArticle(ah.ind, title, id)
+	if lookupErr != nil {
+		NewHttpError(http.StatusBadRequest, "'id' must be a page ID").Send(w)
+		return
+	}
+	if item == nil {
+		NewHttpError(http.StatusNotFound, "Could not find article.").Send(w)
+		return
+	}
+
+	bytes, respErr := json.MarshalIndent(NewArticleInfo(item), "", "  ")
+	if respErr != nil {
+		panic(respErr)
+	}
+	w.Write(bytes)
+	log.Printf("Article '%s'", item.Title)
+}
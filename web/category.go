@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	wp "github.com/wgoodall01/wikipath/wp"
+	"log"
+	"net/http"
+)
+
+type CategoryResponse struct {
+	Category      string   `json:"category"`      // Title of the category.
+	Members       []string `json:"members"`       // Articles in the category.
+	Subcategories []string `json:"subcategories"` // Categories in the category.
+	Parents       []string `json:"parents"`       // Categories the category is in.
+}
+
+type CategoryHandler struct {
+	ind *wp.Index
+}
+
+func NewCategoryHandler(ind *wp.Index) *CategoryHandler {
+	return &CategoryHandler{
+		ind: ind,
+	}
+}
+
+func (ch *CategoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		NewHttpError(http.StatusBadRequest, "'name' query parameter required").Send(w)
+		return
+	}
+
+	category := ch.ind.Category(name)
+	if category == nil {
+		NewHttpError(http.StatusNotFound, "Could not find category.").Send(w)
+		return
+	}
+
+	resp := CategoryResponse{
+		Category:      category.Title,
+		Members:       []string{},
+		Subcategories: []string{},
+		Parents:       []string{},
+	}
+	for _, member := range category.Members {
+		if member.Namespace == wp.NamespaceCategory {
+			resp.Subcategories = append(resp.Subcategories, member.Title)
+		} else {
+			resp.Members = append(resp.Members, member.Title)
+		}
+	}
+	for _, parent := range ch.ind.Categories(category) {
+		resp.Parents = append(resp.Parents, parent.Title)
+	}
+
+	bytes, respErr := json.MarshalIndent(resp, "", "  ")
+	if respErr != nil {
+		panic(respErr)
+	}
+	w.Write(bytes)
+	log.Printf("Category '%s', %d members", category.Title, len(resp.Members))
+}
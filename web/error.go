@@ -9,6 +9,8 @@ type HttpError struct {
 	Status  int    `json:"status"` // Status code
 	kind    string // Unique kind
 	Message string `json:"message"` // Descriptive message
+
+	Choices []string `json:"choices,omitempty"` // Articles a disambiguation page could mean.
 }
 
 func NewHttpError(status int, message string) *HttpError {
@@ -2,18 +2,40 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	wp "github.com/wgoodall01/wikipath/wp"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+type PathHop struct {
+	From     string `json:"from"`               // Article with the link.
+	To       string `json:"to"`                 // Article linked to.
+	Anchor   string `json:"anchor,omitempty"`   // Text of the link.
+	Sentence string `json:"sentence,omitempty"` // Sentence the link is in.
+	Section  string `json:"section,omitempty"`  // Section of From the link is in.
+	Fragment string `json:"fragment,omitempty"` // Section of To linked to.
+
+	Article *ArticleInfo `json:"article"` // The article linked to.
+}
+
 type PathResponse struct {
-	From     string   `json:"from"`     // Starting article
-	To       string   `json:"to"`       // Ending article
-	Path     []string `json:"path"`     // Path between articles.
-	Duration float64  `json:"duration"` // Duration of query.
-	Touched  int      `json:"touched"`  // How many articles touched.
+	From     string    `json:"from"`     // Starting article
+	To       string    `json:"to"`       // Ending article
+	FromID   int       `json:"from_id"`  // Page ID of the starting article
+	ToID     int       `json:"to_id"`    // Page ID of the ending article
+	Path     []string  `json:"path"`     // Path between articles.
+	Route    string    `json:"route"`    // Path as text, with sections, like "A > B#History > C".
+	IDs      []int     `json:"ids"`      // Page ID of each article in Path, 0 if unknown.
+	Hops     []PathHop `json:"hops"`     // Where each article links to the next.
+	Duration float64   `json:"duration"` // Duration of query.
+	Touched  int       `json:"touched"`  // How many articles touched.
+	Edges    string    `json:"edges"`    // Types of link the path could follow.
+
+	Categories bool   `json:"categories"` // If the path could go through categories.
+	Disambig   string `json:"disambig"`   // How the path treated disambiguation pages.
 }
 
 type QueryHandler struct {
@@ -28,17 +50,41 @@ func NewQueryHandler(ind *wp.Index) *QueryHandler {
 
 func (qh *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	fromName := query.Get("from")
-	toName := query.Get("to")
+	fromName, fromID := query.Get("from"), query.Get("from_id")
+	toName, toID := query.Get("to"), query.Get("to_id")
 
-	if fromName == "" || toName == "" {
-		NewHttpError(http.StatusBadRequest, "Both 'from' and 'to' query parameters required").Send(w)
+	if (fromName == "" && fromID == "") || (toName == "" && toID == "") {
+		NewHttpError(http.StatusBadRequest, "Both 'from' and 'to' query parameters required, or 'from_id' and 'to_id'").Send(w)
 		return
 	}
 
-	// Get articles
-	fromItem := qh.ind.Get(fromName)
-	toItem := qh.ind.Get(toName)
+	opts := wp.PathOptions{Edges: wp.EdgeAll}
+	if edges := query.Get("edges"); edges != "" {
+		var edgesErr error
+		opts.Edges, edgesErr = wp.ParseEdgeTypes(edges)
+		if edgesErr != nil {
+			NewHttpError(http.StatusBadRequest, edgesErr.Error()).Send(w)
+			return
+		}
+	}
+
+	opts.Categories = query.Get("categories") == "true"
+	if disambig := query.Get("disambig"); disambig != "" {
+		var disambigErr error
+		opts.Disambiguation, disambigErr = wp.ParseDisambigMode(disambig)
+		if disambigErr != nil {
+			NewHttpError(http.StatusBadRequest, disambigErr.Error()).Send(w)
+			return
+		}
+	}
+
+	// Get articles, by ID if there is one.
+	fromItem, fromErr := lookupArticle(qh.ind, fromName, fromID)
+	toItem, toErr := lookupArticle(qh.ind, toName, toID)
+	if fromErr != nil || toErr != nil {
+		NewHttpError(http.StatusBadRequest, "'from_id' and 'to_id' must be page IDs").Send(w)
+		return
+	}
 
 	if fromItem == nil {
 		NewHttpError(http.StatusNotFound, "Could not find 'from' article.").Send(w)
@@ -49,9 +95,26 @@ func (qh *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A disambiguation page is rarely what was meant, so clients which can
+	// handle it can ask with 'choices' to be offered what it lists instead.
+	if query.Get("choices") == "true" {
+		for _, item := range []*wp.IndexItem{fromItem, toItem} {
+			choices := qh.ind.Choices(item)
+			if len(choices) == 0 {
+				continue
+			}
+			he := NewHttpError(http.StatusMultipleChoices, fmt.Sprintf("'%s' is a disambiguation page", item.Title))
+			for _, choice := range choices {
+				he.Choices = append(he.Choices, choice.Title)
+			}
+			he.Send(w)
+			return
+		}
+	}
+
 	// Find path.
 	tStart := time.Now()
-	path, touched := qh.ind.FindPath(fromItem, toItem, MAX_DEPTH)
+	path, touched := qh.ind.FindPathWith(fromItem, toItem, MAX_DEPTH, opts)
 	duration := time.Since(tStart)
 
 	if path == nil {
@@ -59,13 +122,43 @@ func (qh *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Articles asked for by ID are named by their titles.
+	if fromName == "" {
+		fromName = fromItem.Title
+	}
+	if toName == "" {
+		toName = toItem.Title
+	}
+
 	titles := path.ToStringSlice()
+	ids := []int{}
+	for _, item := range path.ToSlice() {
+		ids = append(ids, item.ID)
+	}
+	hops := []PathHop{}
+	for _, hop := range path.Hops() {
+		ph := PathHop{From: hop.From.Title, To: hop.To.Title, Article: NewArticleInfo(hop.To)}
+		if hop.Context != nil {
+			ph.Anchor, ph.Sentence = hop.Context.Anchor, hop.Context.Sentence
+			ph.Section, ph.Fragment = hop.Context.Section, hop.Context.Fragment
+		}
+		hops = append(hops, ph)
+	}
 	resp := PathResponse{
 		From:     fromName,
 		To:       toName,
+		FromID:   fromItem.ID,
+		ToID:     toItem.ID,
 		Path:     titles,
+		Route:    path.String(),
+		IDs:      ids,
+		Hops:     hops,
 		Duration: duration.Seconds(),
 		Touched:  touched,
+		Edges:    opts.Edges.String(),
+
+		Categories: opts.Categories,
+		Disambig:   opts.Disambiguation.String(),
 	}
 
 	respBytes, respErr := json.MarshalIndent(resp, "", "  ")
@@ -76,3 +169,16 @@ func (qh *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(respBytes)
 	log.Printf("'%s' -> '%s' in %0.2f", fromName, toName, duration.Seconds())
 }
+
+// lookupArticle gets an article by its title, or by its page ID if `id` is
+// set.
+func lookupArticle(ind *wp.Index, title string, id string) (*wp.IndexItem, error) {
+	if id == "" {
+		return ind.Get(title), nil
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	return ind.GetByID(n), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	wp "github.com/wgoodall01/wikipath/wp"
+	"log"
+	"net/http"
+)
+
+type SectionInfo struct {
+	Heading string   `json:"heading"` // Heading of the section, "" for the lead.
+	Title   string   `json:"title"`   // Title of the section, like "Queen (band)#History".
+	Links   []string `json:"links"`   // Articles the section links to.
+}
+
+type SectionsResponse struct {
+	Article  string        `json:"article"`  // Title of the article.
+	Sections []SectionInfo `json:"sections"` // Sections of the article, empty if they aren't known.
+}
+
+type SectionsHandler struct {
+	ind *wp.Index
+}
+
+func NewSectionsHandler(ind *wp.Index) *SectionsHandler {
+	return &SectionsHandler{
+		ind: ind,
+	}
+}
+
+func (sh *SectionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	title, id := query.Get("title"), query.Get("id")
+	if title == "" && id == "" {
+		NewHttpError(http.StatusBadRequest, "'title' or 'id' query parameter required").Send(w)
+		return
+	}
+
+	item, lookupErr := lookupArticle(sh.ind, title, id)
+	if lookupErr != nil {
+		NewHttpError(http.StatusBadRequest, "'id' must be a page ID").Send(w)
+		return
+	}
+	if item == nil {
+		NewHttpError(http.StatusNotFound, "Could not find article.").Send(w)
+		return
+	}
+
+	resp := SectionsResponse{
+		Article:  item.Title,
+		Sections: []SectionInfo{},
+	}
+	for _, section := range sh.ind.Sections(item) {
+		info := SectionInfo{Heading: section.Heading, Title: section.Title(), Links: []string{}}
+		for _, dst := range section.Forward {
+			info.Links = append(info.Links, dst.Title)
+		}
+		resp.Sections = append(resp.Sections, info)
+	}
+
+	bytes, respErr := json.MarshalIndent(resp, "", "  ")
+	if respErr != nil {
+		panic(respErr)
+	}
+	w.Write(bytes)
+	log.Printf("Sections of '%s', %d sections", item.Title, len(resp.Sections))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	wp "github.com/wgoodall01/wikipath/wp"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const MAX_TOP int = 100 // Most of the most linked articles to list.
+
+type StatsHandler struct {
+	ind *wp.Index
+
+	once  sync.Once
+	stats *wp.IndexStats // Worked out on the first request, with MAX_TOP most linked.
+}
+
+func NewStatsHandler(ind *wp.Index) *StatsHandler {
+	return &StatsHandler{
+		ind: ind,
+	}
+}
+
+func (sh *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	top := 10
+	if topParam := r.URL.Query().Get("top"); topParam != "" {
+		var topErr error
+		top, topErr = strconv.Atoi(topParam)
+		if topErr != nil || top < 0 || top > MAX_TOP {
+			NewHttpError(http.StatusBadRequest, "'top' must be a number from 0 to "+strconv.Itoa(MAX_TOP)).Send(w)
+			return
+		}
+	}
+
+	// The index doesn't change, so neither do its stats.
+	sh.once.Do(func() {
+		sh.stats = sh.ind.Stats(MAX_TOP)
+	})
+
+	res := *sh.stats
+	if top < len(res.MostLinked) {
+		res.MostLinked = res.MostLinked[:top]
+	}
+	bytes, respErr := json.MarshalIndent(res, "", "  ")
+	if respErr != nil {
+		panic(respErr)
+	}
+	w.Write(bytes)
+	log.Printf("Stats")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	wp "github.com/wgoodall01/wikipath/wp"
+	"log"
+	"net/http"
+)
+
+type SummaryResponse struct {
+	Title   string `json:"title"`   // Title of the article.
+	ID      int    `json:"id"`      // Page ID of the article.
+	Summary string `json:"summary"` // Lead paragraph, as plain text.
+}
+
+type SummaryHandler struct {
+	ind       *wp.Index
+	summaries *wp.Summaries
+}
+
+func NewSummaryHandler(ind *wp.Index, summaries *wp.Summaries) *SummaryHandler {
+	return &SummaryHandler{
+		ind:       ind,
+		summaries: summaries,
+	}
+}
+
+func (sh *SummaryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if sh.summaries == nil {
+		NewHttpError(http.StatusNotFound, "No summaries were loaded.").Send(w)
+		return
+	}
+
+	query := r.URL.Query()
+	title, id := query.Get("title"), query.Get("id")
+	if title == "" && id == "" {
+		NewHttpError(http.StatusBadRequest, "'title' or 'id' query parameter required").Send(w)
+		return
+	}
+
+	item, lookupErr := lookupArticle(sh.ind, title, id)
+	if lookupErr != nil {
+		NewHttpError(http.StatusBadRequest, "'id' must be a page ID").Send(w)
+		return
+	}
+	if item == nil {
+		NewHttpError(http.StatusNotFound, "Could not find article.").Send(w)
+		return
+	}
+
+	summary, summaryErr := sh.summaries.Get(item.ID)
+	if summaryErr == wp.ErrNoSummary {
+		NewHttpError(http.StatusNotFound, "Article has no summary.").Send(w)
+		return
+	} else if summaryErr != nil {
+		log.Printf("Summary of '%s': %v", item.Title, summaryErr)
+		NewHttpError(http.StatusInternalServerError, "Could not read summary.").Send(w)
+		return
+	}
+
+	res := SummaryResponse{Title: item.Title, ID: item.ID, Summary: summary}
+	bytes, respErr := json.MarshalIndent(res, "", "  ")
+	if respErr != nil {
+		panic(respErr)
+	}
+	w.Write(bytes)
+	log.Printf("Summary of '%s'", item.Title)
+}
@@ -44,6 +44,15 @@ func main() {
 	startLoad := time.Now()
 	idx := wp.NewIndex()
 
+	// Only articles are loaded, unless WIKIPATH_NAMESPACES lists others.
+	if list := os.Getenv("WIKIPATH_NAMESPACES"); list != "" {
+		namespaces, nsErr := wp.ParseNamespaces(list, wp.DefaultLinkParser)
+		if nsErr != nil {
+			log.Fatalf("fatal: WIKIPATH_NAMESPACES: %v", nsErr)
+		}
+		idx.SetNamespaces(namespaces)
+	}
+
 	for {
 		sa, readErr := wir.ReadArticle()
 		if readErr == wp.EOF {
@@ -65,6 +74,23 @@ func main() {
 	durBuild := time.Since(startBuild)
 	log.Printf("Built index in %.2fs", durBuild.Seconds())
 
+	// Summaries are read from the side file as they're asked for, if
+	// `wikipath index --summaries` made one.
+	var summaries *wp.Summaries
+	summariesPath := wp.SummariesPath(indexPath)
+	if summariesFile, openErr := os.Open(summariesPath); openErr == nil {
+		info, statErr := summariesFile.Stat()
+		if statErr != nil {
+			log.Fatalf("fatal: couldn't open summaries: %v", statErr)
+		}
+		var summariesErr error
+		summaries, summariesErr = wp.OpenSummaries(summariesFile, info.Size())
+		if summariesErr != nil {
+			log.Fatalf("fatal: couldn't read summaries: %v", summariesErr)
+		}
+		log.Printf("Loaded %d summaries from '%s'", summaries.Len(), summariesPath)
+	}
+
 	// Start webserver.
 	statikFS, statikErr := fs.New()
 	if statikErr != nil {
@@ -73,6 +99,11 @@ func main() {
 
 	http.Handle("/api/query", NewQueryHandler(idx))
 	http.Handle("/api/random", NewRandomHandler(idx))
+	http.Handle("/api/category", NewCategoryHandler(idx))
+	http.Handle("/api/article", NewArticleHandler(idx))
+	http.Handle("/api/sections", NewSectionsHandler(idx))
+	http.Handle("/api/summary", NewSummaryHandler(idx, summaries))
+	http.Handle("/api/stats", NewStatsHandler(idx))
 	http.Handle("/", http.FileServer(statikFS))
 
 	log.Printf("Listening on :8080")
@@ -0,0 +1,173 @@
+package wikipath
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// ArchiveKind is the kind of file a wiki archive is.
+type ArchiveKind int
+
+const (
+	ArchiveXML         ArchiveKind = iota // Uncompressed XML, like Special:Export gives.
+	ArchiveGzip                           // Gzipped XML.
+	ArchiveBzip2                          // XML compressed in one bzip2 stream.
+	ArchiveMultistream                    // XML in many bzip2 streams, like *-multistream.xml.bz2.
+	ArchiveZim                            // A ZIM file of HTML articles, as Kiwix uses.
+	ArchiveHTMLDump                       // A Wikimedia Enterprise HTML dump, of JSON lines.
+)
+
+func (k ArchiveKind) String() string {
+	switch k {
+	case ArchiveXML:
+		return "xml"
+	case ArchiveGzip:
+		return "gzip"
+	case ArchiveBzip2:
+		return "bzip2"
+	case ArchiveMultistream:
+		return "multistream"
+	case ArchiveZim:
+		return "zim"
+	case ArchiveHTMLDump:
+		return "html-dump"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownArchive is returned for files which don't look like a wiki archive.
+var ErrUnknownArchive = errors.New("not an XML, gzip, bzip2, ZIM or HTML dump file")
+
+// streamMagic is what a bzip2 stream with any data in it starts with, after
+// the "BZh" and block size digit: the magic number of its first block.
+var streamMagic = []byte("1AY&SY")
+
+// detectScanSize is how far into a bzip2 archive DetectArchive looks for a
+// second stream. Multistream dumps start new streams every hundred pages.
+const detectScanSize = 16 << 20
+
+// isStreamStart returns true if `b` starts with the header of a bzip2 stream.
+func isStreamStart(b []byte) bool {
+	return len(b) >= 10 &&
+		string(b[:3]) == "BZh" && b[3] >= '1' && b[3] <= '9' &&
+		bytes.Equal(b[4:10], streamMagic)
+}
+
+// DetectArchive works out what kind of archive a file is from its first
+// bytes, decompressed if it's gzipped. A bzip2 file is a multistream archive
+// if a second stream starts within its first few megabytes.
+func DetectArchive(f io.ReaderAt, size int64) (ArchiveKind, error) {
+	head := make([]byte, 512)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	head = head[:n]
+
+	switch {
+	case len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b:
+		// Gzipped XML, or a gzipped HTML dump.
+		gz, err := gzip.NewReader(io.NewSectionReader(f, 0, size))
+		if err != nil {
+			return 0, err
+		}
+		inner := make([]byte, 512)
+		n, _ := io.ReadFull(gz, inner)
+		if isHTMLDump(inner[:n]) {
+			return ArchiveHTMLDump, nil
+		}
+		return ArchiveGzip, nil
+	case len(head) >= 4 && string(head[:4]) == "ZIM\x04":
+		return ArchiveZim, nil
+	case isStreamStart(head):
+		scan := size
+		if scan > detectScanSize {
+			scan = detectScanSize
+		}
+		streams, err := FindStreams(io.NewSectionReader(f, 0, scan), 2)
+		if err != nil {
+			return 0, err
+		}
+		if len(streams) > 1 {
+			return ArchiveMultistream, nil
+		}
+		return ArchiveBzip2, nil
+	case isHTMLDump(head):
+		return ArchiveHTMLDump, nil
+	}
+
+	// XML, maybe after a byte order mark or some whitespace.
+	text := bytes.TrimLeft(head, "\xef\xbb\xbf \t\r\n")
+	if len(text) == 0 || text[0] == '<' {
+		return ArchiveXML, nil
+	}
+	return 0, ErrUnknownArchive
+}
+
+// FindStreams scans a multistream archive for the offsets its bzip2 streams
+// start at, for reading it without an index. It stops after `limit`
+// streams, or reads the whole file if `limit` is 0.
+//
+// Stream headers are byte-aligned, but could in theory turn up by chance in
+// compressed data; the 10 bytes matched make that vanishingly unlikely.
+func FindStreams(r io.Reader, limit int) ([]int64, error) {
+	const bufSize = 1 << 20
+	overlap := len("BZh9") + len(streamMagic) - 1
+
+	var streams []int64
+	buf := make([]byte, bufSize)
+	var base int64 // Offset of buf[0] in the file.
+	kept := 0      // Bytes carried over from the last read.
+
+	for {
+		n, err := io.ReadFull(r, buf[kept:])
+		end := kept + n
+
+		for i := 0; i+len("BZh9")+len(streamMagic) <= end; {
+			j := bytes.Index(buf[i+4:end], streamMagic)
+			if j < 0 {
+				break
+			}
+			start := i + j
+			if isStreamStart(buf[start:end]) {
+				streams = append(streams, base+int64(start))
+				if limit > 0 && len(streams) >= limit {
+					return streams, nil
+				}
+			}
+			i = start + 1
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return streams, nil
+		} else if err != nil {
+			return streams, err
+		}
+
+		// Keep the tail, in case a header straddles two reads.
+		kept = overlap
+		copy(buf, buf[end-kept:end])
+		base += int64(end - kept)
+	}
+}
+
+// OpenArchive returns a reader over the XML of an archive which isn't
+// multistream, decompressing it if needed. ZIM files and HTML dumps aren't
+// XML, and are read with LoadZim and LoadHTMLDump instead.
+func OpenArchive(f io.Reader, kind ArchiveKind) (io.Reader, error) {
+	switch kind {
+	case ArchiveXML:
+		return f, nil
+	case ArchiveGzip:
+		return gzip.NewReader(f)
+	case ArchiveBzip2, ArchiveMultistream:
+		// compress/bzip2 reads concatenated streams one after another.
+		return bzip2.NewReader(f), nil
+	default:
+		return nil, ErrUnknownArchive
+	}
+}
@@ -0,0 +1,122 @@
+package wikipath
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const (
+	multistreamPath      = "testdata/multistream.xml.bz2"
+	multistreamIndexPath = "testdata/multistream-index.txt.bz2"
+	singleArchivePath    = "testdata/single.xml.bz2"
+)
+
+func openTestFile(t *testing.T, path string) *os.File {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func readTestFile(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDetectArchive(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(testXML))
+	w.Close()
+
+	multistream := readTestFile(t, multistreamPath)
+	single := readTestFile(t, singleArchivePath)
+
+	cases := []struct {
+		name string
+		data []byte
+		kind ArchiveKind
+	}{
+		{"XML", []byte(testXML), ArchiveXML},
+		{"BOM", []byte("\xef\xbb\xbf<mediawiki>"), ArchiveXML},
+		{"Gzip", gz.Bytes(), ArchiveGzip},
+		{"Bzip2", single, ArchiveBzip2},
+		{"Multistream", multistream, ArchiveMultistream},
+		{"ZIM", buildZim(t, zimTestEntries, zimNone), ArchiveZim},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			kind, err := DetectArchive(bytes.NewReader(tc.data), int64(len(tc.data)))
+			assertEqual(t, err, nil)
+			assertEqual(t, kind, tc.kind)
+		})
+	}
+
+	_, err := DetectArchive(bytes.NewReader([]byte("PK\x03\x04")), 4)
+	assertEqual(t, err, ErrUnknownArchive)
+}
+
+func TestFindStreams(t *testing.T) {
+	archiveFile := openTestFile(t, multistreamPath)
+
+	// A header stream, 40 streams of 5 pages, and a footer stream.
+	streams, err := FindStreams(archiveFile, 0)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(streams), 42)
+	assertEqual(t, streams[0], int64(0))
+
+	// Every stream in the index is found.
+	ec := NewErrorContext()
+	chunks := loadIndexChunks(ec, openTestFile(t, multistreamIndexPath), nil, nil)
+	found := make(map[int64]bool)
+	for _, s := range streams {
+		found[s] = true
+	}
+	for chunk := range chunks {
+		assertEqual(t, found[chunk.Start], true)
+	}
+}
+
+func TestLoadWikiStreams(t *testing.T) {
+	archiveFile := openTestFile(t, multistreamPath)
+	streams, err := FindStreams(archiveFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nChunks, nArticles := 0, 0
+	err = LoadWikiStreams(NewErrorContext(), streams, archiveFile, ChunkOptions{Ordered: true}, func(_ Chunk, articles []*Article) bool {
+		nChunks++
+		nArticles += len(articles)
+		return true
+	})
+	assertEqual(t, err, nil)
+	assertEqual(t, nChunks, 42)
+	assertEqual(t, nArticles, 200)
+}
+
+func TestOpenArchive(t *testing.T) {
+	for _, path := range []string{singleArchivePath, multistreamPath} {
+		f := openTestFile(t, path)
+		kind, err := DetectArchive(f, 1<<20)
+		assertEqual(t, err, nil)
+
+		xml, err := OpenArchive(f, kind)
+		assertEqual(t, err, nil)
+
+		n := 0
+		LoadWiki(xml, func(a *Article) bool {
+			n++
+			return true
+		})
+		assertEqual(t, n, 200)
+	}
+}
@@ -0,0 +1,109 @@
+package wikipath
+
+import "strings"
+
+// ShortDescription gets the text of a page's {{Short description}} as plain
+// text, or "" if it hasn't got one or it's "none".
+func (lp *LinkParser) ShortDescription(text string) string {
+	for i := strings.Index(text, "{{"); i >= 0; {
+		if templateName(text[i+2:]) == "short description" {
+			args := templateArgs(text, i)
+			if len(args) < 2 {
+				return ""
+			}
+			desc := lp.plainText(strings.TrimPrefix(strings.TrimSpace(args[1]), "1="))
+			if strings.EqualFold(desc, "none") {
+				return ""
+			}
+			return desc
+		}
+		next := strings.Index(text[i+2:], "{{")
+		if next < 0 {
+			break
+		}
+		i += 2 + next
+	}
+	return ""
+}
+
+// maxLead is the most bytes of a lead paragraph kept.
+const maxLead = 2000
+
+// LeadParagraph gets the first paragraph of an article as plain text: the
+// first one before any heading which has some text once templates, files,
+// tables and references are left out. Returns "" if there isn't one.
+func (lp *LinkParser) LeadParagraph(text string) string {
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '\n' || text[i] == ' ' || text[i] == '\t' || text[i] == '\r':
+			i++
+
+		case text[i] == '=':
+			return "" // The first section has started.
+
+		case strings.HasPrefix(text[i:], "{{"):
+			i = templateEnd(text, i)
+
+		case strings.HasPrefix(text[i:], "{|"):
+			// A table, which ends with a line starting "|}".
+			end := strings.Index(text[i:], "\n|}")
+			if end < 0 {
+				return ""
+			}
+			i += end + len("\n|}")
+
+		case strings.HasPrefix(text[i:], "<!--"):
+			end := strings.Index(text[i:], "-->")
+			if end < 0 {
+				return ""
+			}
+			i += end + len("-->")
+
+		case strings.HasPrefix(text[i:], "__"):
+			// A magic word, like __NOTOC__.
+			end := strings.Index(text[i+2:], "__")
+			if end < 0 {
+				return ""
+			}
+			i += 2 + end + 2
+
+		case text[i] == ':' || text[i] == '*' || text[i] == '#' || text[i] == '[' && lp.isMedia(text, i):
+			// Hatnotes, lists, and files or categories on their own line.
+			if nl := strings.IndexByte(text[i:], '\n'); nl >= 0 {
+				i += nl + 1
+			} else {
+				return ""
+			}
+
+		default:
+			end := len(text)
+			for _, stop := range []string{"\n\n", "\n=", "\n{|"} {
+				if j := strings.Index(text[i:], stop); j >= 0 && i+j < end {
+					end = i + j
+				}
+			}
+			if lead := lp.plainText(text[i:end]); lead != "" {
+				return clipText(lead, maxLead)
+			}
+			i = end
+		}
+	}
+	return ""
+}
+
+// isMedia returns true if text[i] starts a link to a file or category.
+func (lp *LinkParser) isMedia(text string, i int) bool {
+	if !strings.HasPrefix(text[i:], "[[") {
+		return false
+	}
+	end := linkEnd(text, i)
+	if end < 0 {
+		return false
+	}
+	inner := text[i+2 : end-2]
+	if pipe := strings.IndexByte(inner, '|'); pipe >= 0 {
+		inner = inner[:pipe]
+	}
+	l, ok := lp.newLink(inner)
+	return ok && (l.Kind == LinkFile || l.Kind == LinkCategory)
+}
@@ -0,0 +1,57 @@
+package wikipath
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShortDescription(t *testing.T) {
+	for text, want := range map[string]string{
+		"{{Short description|British rock band}}\n{{Infobox band}}":                   "British rock band",
+		"{{Use dmy dates}}{{short description|1=[[Planet]] of the ''Solar System''}}": "Planet of the Solar System",
+		"{{Short description|none}}":                                                  "",
+		"{{Short description}}":                                                       "",
+		"[[Short description]] {{Infobox|short=true}}":                                "",
+	} {
+		if got := DefaultLinkParser.ShortDescription(text); got != want {
+			t.Errorf("ShortDescription(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	a := &Article{
+		Title:             "Queen (band)",
+		ID:                42010,
+		Text:              "{{Short description|British rock band}} [[Freddie Mercury]] and [[Brian May]].",
+		RevisionID:        1200,
+		RevisionTimestamp: "2024-05-01T12:30:00Z",
+	}
+	sa := NewStrippedArticle(a)
+	assertEqual(t, sa.RevisionID, 1200)
+	assertEqual(t, sa.Timestamp.Equal(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)), true)
+	assertEqual(t, sa.Size, len(a.Text))
+	assertEqual(t, sa.Description, "British rock band")
+
+	index := NewIndex()
+	index.AddArticle(sa)
+	index.Build()
+	item := index.Get("Queen (band)")
+	assertEqual(t, item.LinkCount, 2)
+	assertEqual(t, item.Size, sa.Size)
+	assertEqual(t, item.Description, "British rock band")
+}
+
+func TestLeadParagraph(t *testing.T) {
+	for text, want := range map[string]string{
+		"{{Short description|British rock band}}\n{{Infobox band\n| name = Queen\n}}\n'''Queen''' are a British [[rock music|rock]] band.<ref>{{cite web|title=Queen}}</ref> They formed in [[London]].\n\nMore.": "Queen are a British rock band. They formed in London.",
+		"{{About|the band}}\n[[File:Queen.jpg|thumb|The band]]\n:''See also [[Queen (disambiguation)]]''\n<!-- A comment -->__NOTOC__\nQueen were a band.\n== History ==":                                         "Queen were a band.",
+		"{{Infobox}}\n== History ==\nQueen formed in 1970.":  "",
+		"{| class=wikitable\n| A\n|}\n\nA table came first.": "A table came first.",
+		"{{Unclosed template\nQueen were a band.":            "",
+	} {
+		if got := DefaultLinkParser.LeadParagraph(text); got != want {
+			t.Errorf("LeadParagraph(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package wikipath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DisambigMode is how a path search treats disambiguation pages, like
+// "Mercury (disambiguation)", which link to every article of their name.
+type DisambigMode uint8
+
+const (
+	DisambigFollow DisambigMode = iota // Go through them like any other page.
+	DisambigAvoid                      // Never go through them.
+
+	// DisambigFree goes through them as if linking to one cost nothing, by
+	// searching them before any page further away. They're still in the
+	// path, and count towards its length.
+	DisambigFree
+)
+
+// disambigModeNames are the names of each DisambigMode, in order.
+var disambigModeNames = []string{"follow", "avoid", "free"}
+
+// String gives the mode's name.
+func (m DisambigMode) String() string {
+	if int(m) < len(disambigModeNames) {
+		return disambigModeNames[m]
+	}
+	return fmt.Sprintf("DisambigMode(%d)", int(m))
+}
+
+// MarshalText writes the mode's name, for the API.
+func (m DisambigMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// ParseDisambigMode parses the name of a DisambigMode.
+func ParseDisambigMode(name string) (DisambigMode, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range disambigModeNames {
+		if n == name {
+			return DisambigMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown disambiguation mode '%s', expected one of %s", name, strings.Join(disambigModeNames, ", "))
+}
+
+// IsDisambiguation returns true if some wikitext is a disambiguation page:
+// it has the __DISAMBIG__ magic word, or one of the DisambigTemplates.
+func (lp *LinkParser) IsDisambiguation(text string) bool {
+	if strings.Contains(text, "__DISAMBIG__") {
+		return true
+	}
+	for i := strings.Index(text, "{{"); i >= 0; {
+		name := templateName(text[i+2:])
+		if lp.DisambigTemplates[name] || strings.HasSuffix(name, " disambiguation") {
+			return true
+		}
+		next := strings.Index(text[i+2:], "{{")
+		if next < 0 {
+			break
+		}
+		i += 2 + next
+	}
+	return false
+}
+
+// Choices gets the articles a disambiguation page lists, which are the ones
+// in its lists, or all it links to if it has none. Returns nil if `item`
+// isn't a disambiguation page.
+func (ind *Index) Choices(item *IndexItem) []*IndexItem {
+	if !item.Disambiguation {
+		return nil
+	}
+	if !ind.ready {
+		ind.Build()
+	}
+
+	var choices []*IndexItem
+	for i, it := range item.Forward {
+		if item.ForwardEdges[i]&(EdgeList|EdgeTable) != 0 && !it.Disambiguation {
+			choices = append(choices, it)
+		}
+	}
+	if len(choices) == 0 {
+		return item.Forward
+	}
+	return choices
+}
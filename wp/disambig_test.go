@@ -0,0 +1,74 @@
+package wikipath
+
+import "testing"
+
+func TestIsDisambiguation(t *testing.T) {
+	for text, want := range map[string]bool{
+		"'''Mercury''' may refer to:\n* [[Mercury (planet)]]\n{{disambiguation}}": true,
+		"{{Dab|surname}}":                                true,
+		"{{ Human name disambiguation }}":                true,
+		"__DISAMBIG__ {{Mercury}}":                       true,
+		"{{Infobox planet}} [[Mercury]] {{dab}}x":        true,
+		"{{Disambiguation needed}} {{about|the planet}}": false,
+		"[[Disambiguation]] is when {{{dab}}":            false,
+	} {
+		if got := DefaultLinkParser.IsDisambiguation(text); got != want {
+			t.Errorf("IsDisambiguation(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestDisambiguation(t *testing.T) {
+	index := NewIndex()
+	for _, a := range []*Article{
+		{Title: "Freddie Mercury", Text: "[[Queen (band)|Queen]] [[Mercury]]"},
+		{Title: "Mercury", Text: "'''Mercury''' may refer to:\n* [[Mercury (planet)]]\n* [[Mercury (element)]]\n\n[[Freddie Mercury]]\n{{disambiguation}}"},
+		{Title: "Queen (band)", Text: "[[Brian May]]"},
+		{Title: "Brian May", Text: "[[Astronomy]]"},
+		{Title: "Astronomy", Text: "[[Mercury (planet)]]"},
+		{Title: "Mercury (planet)", Text: "[[Astronomy]]"},
+		{Title: "Mercury (element)"},
+		{Title: "Planets", Text: "[[Solar System]] [[Merkur]]"},
+		{Title: "Merkur", Text: "'''Merkur''' may mean:\n* [[Mercury]]\n{{dab}}"},
+		{Title: "Solar System", Text: "[[Mercury (planet)]]"},
+	} {
+		index.AddArticle(NewStrippedArticle(a))
+	}
+	index.Build()
+
+	mercury, planet := index.Get("Mercury"), index.Get("Mercury (planet)")
+	assertEqual(t, mercury.Disambiguation, true)
+	assertEqual(t, planet.Disambiguation, false)
+	assertEqual(t, index.Choices(planet) == nil, true)
+	choices := index.Choices(mercury)
+	assertEqual(t, len(choices), 2)
+	assertEqual(t, choices[1].Title, "Mercury (element)")
+
+	freddie := index.Get("Freddie Mercury")
+	for mode, want := range map[DisambigMode]string{
+		DisambigFollow: "Freddie Mercury > Mercury > Mercury (planet)",
+		DisambigAvoid:  "Freddie Mercury > Queen (band) > Brian May > Astronomy > Mercury (planet)",
+		DisambigFree:   "Freddie Mercury > Mercury > Mercury (planet)",
+	} {
+		path, _ := index.FindPathWith(freddie, planet, 10, PathOptions{Disambiguation: mode})
+		assertEqual(t, path.String(), want)
+	}
+
+	// Links to free pages don't count while searching, but they're still in
+	// the path.
+	planets := index.Get("Planets")
+	path, _ := index.FindPathWith(planets, planet, 10, PathOptions{})
+	assertEqual(t, path.String(), "Planets > Solar System > Mercury (planet)")
+	path, _ = index.FindPathWith(planets, planet, 10, PathOptions{Disambiguation: DisambigFree})
+	assertEqual(t, path.String(), "Planets > Merkur > Mercury > Mercury (planet)")
+
+	// The ends of a path can be disambiguation pages.
+	path, _ = index.FindPathWith(freddie, mercury, 10, PathOptions{Disambiguation: DisambigAvoid})
+	assertEqual(t, path.String(), "Freddie Mercury > Mercury")
+
+	mode, err := ParseDisambigMode("Free")
+	assertEqual(t, err, nil)
+	assertEqual(t, mode, DisambigFree)
+	_, err = ParseDisambigMode("skip")
+	assertEqual(t, err != nil, true)
+}
@@ -0,0 +1,87 @@
+package wikipath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EdgeType is the part of an article a link is in. Types are bits, so a set
+// of them can be held in one EdgeType, and a link which is in an article
+// more than once can have more than one type.
+type EdgeType uint8
+
+const (
+	EdgeProse   EdgeType = 1 << iota // The body of the article.
+	EdgeInfobox                      // An infobox, like {{Infobox musical artist}}.
+	EdgeNavbox                       // A navigation template, like {{Navbox}} or a sidebar.
+	EdgeSeeAlso                      // The "See also" section, or a {{See also}} hatnote.
+	EdgeList                         // A bulleted or numbered list.
+	EdgeTable                        // A table.
+
+	// EdgeAll is every type of edge.
+	EdgeAll = EdgeProse | EdgeInfobox | EdgeNavbox | EdgeSeeAlso | EdgeList | EdgeTable
+)
+
+// edgeNames are the names of each EdgeType, in bit order.
+var edgeNames = []string{"prose", "infobox", "navbox", "see-also", "list", "table"}
+
+// String gives the names of the types in a set, joined with commas.
+func (e EdgeType) String() string {
+	var names []string
+	for i, name := range edgeNames {
+		if e&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// MarshalText writes the set's names, for golden files and the API.
+func (e EdgeType) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// ParseEdgeTypes parses a list of edge types separated by commas, like
+// "prose,see-also". "all" is every type. A '-' before a name leaves it out,
+// so "-infobox,-navbox" is everything but infoboxes and navboxes. A list
+// which leaves out every type, like "-all", is an error.
+func ParseEdgeTypes(list string) (EdgeType, error) {
+	var set EdgeType
+	for i, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		remove := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		if remove && i == 0 {
+			set = EdgeAll
+		}
+
+		var bits EdgeType
+		if name == "all" {
+			bits = EdgeAll
+		}
+		for bit, n := range edgeNames {
+			if n == name {
+				bits = 1 << uint(bit)
+			}
+		}
+		if bits == 0 {
+			return 0, fmt.Errorf("unknown edge type '%s', expected one of %s or all", name, strings.Join(edgeNames, ", "))
+		}
+
+		if remove {
+			set &^= bits
+		} else {
+			set |= bits
+		}
+	}
+	if set == 0 {
+		return 0, fmt.Errorf("edge types '%s' leave out every type of link", list)
+	}
+	return set, nil
+}
@@ -0,0 +1,213 @@
+package wikipath
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ExportFormat is a file format the link graph can be exported to.
+type ExportFormat string
+
+const (
+	ExportCSV          ExportFormat = "csv"     // Edge list, one "source,target" row per link.
+	ExportGraphML      ExportFormat = "graphml" // GraphML, for networkx, igraph, yEd.
+	ExportGEXF         ExportFormat = "gexf"    // GEXF 1.2, for Gephi.
+	ExportDOT          ExportFormat = "dot"     // Graphviz DOT.
+	ExportMatrixMarket ExportFormat = "mtx"     // Matrix Market sparse adjacency matrix.
+)
+
+// ExportFormats lists every ExportFormat.
+var ExportFormats = []ExportFormat{ExportCSV, ExportGraphML, ExportGEXF, ExportDOT, ExportMatrixMarket}
+
+// ParseExportFormat parses the name of an ExportFormat.
+func ParseExportFormat(name string) (ExportFormat, error) {
+	for _, f := range ExportFormats {
+		if string(f) == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format '%s'", name)
+}
+
+// graphExport is the set of articles being exported, numbered by their
+// position in title order.
+type graphExport struct {
+	items []*IndexItem
+	ids   map[*IndexItem]int
+}
+
+func newGraphExport(items []*IndexItem) *graphExport {
+	sorted := make([]*IndexItem, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Title < sorted[j].Title })
+
+	ids := make(map[*IndexItem]int, len(sorted))
+	for i, it := range sorted {
+		ids[it] = i
+	}
+	return &graphExport{items: sorted, ids: ids}
+}
+
+// edges calls `fn` with the node numbers of each link between two exported
+// articles. An article linking to another several times is one edge.
+func (ge *graphExport) edges(fn func(from int, to int) error) error {
+	for from, it := range ge.items {
+		seen := make(map[int]bool, len(it.Forward))
+		for _, link := range it.Forward {
+			to, ok := ge.ids[link]
+			if !ok || seen[to] {
+				continue
+			}
+			seen[to] = true
+			if err := fn(from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExportGraph writes the links between `items` to `w` in the given format.
+// Links to articles outside of `items` are left out, so passing a Ball
+// exports just the subgraph around its seeds. Nodes are numbered in title
+// order, so the same index always exports the same file.
+func (ind *Index) ExportGraph(w io.Writer, format ExportFormat, items []*IndexItem) error {
+	if !ind.ready {
+		ind.Build()
+	}
+
+	ge := newGraphExport(items)
+	out := bufio.NewWriter(w)
+
+	var err error
+	switch format {
+	case ExportCSV:
+		err = ge.csv(out)
+	case ExportGraphML:
+		err = ge.graphml(out)
+	case ExportGEXF:
+		err = ge.gexf(out)
+	case ExportDOT:
+		err = ge.dot(out)
+	case ExportMatrixMarket:
+		err = ge.matrixMarket(out)
+	default:
+		err = fmt.Errorf("unknown export format '%s'", format)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func (ge *graphExport) csv(w *bufio.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source", "target"})
+	err := ge.edges(func(from int, to int) error {
+		return cw.Write([]string{ge.items[from].Title, ge.items[to].Title})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (ge *graphExport) graphml(w *bufio.Writer) error {
+	fmt.Fprint(w, xml.Header)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(w, `  <key id="label" for="node" attr.name="label" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <graph id="wikipath" edgedefault="directed">`)
+	for i, it := range ge.items {
+		fmt.Fprintf(w, "    <node id=\"n%d\"><data key=\"label\">%s</data></node>\n", i, xmlEscape(it.Title))
+	}
+	err := ge.edges(func(from int, to int) error {
+		_, err := fmt.Fprintf(w, "    <edge source=\"n%d\" target=\"n%d\"/>\n", from, to)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, `  </graph>`)
+	_, err = fmt.Fprintln(w, `</graphml>`)
+	return err
+}
+
+func (ge *graphExport) gexf(w *bufio.Writer) error {
+	fmt.Fprint(w, xml.Header)
+	fmt.Fprintln(w, `<gexf xmlns="http://www.gexf.net/1.2draft" version="1.2">`)
+	fmt.Fprintln(w, `  <graph mode="static" defaultedgetype="directed">`)
+	fmt.Fprintln(w, `    <nodes>`)
+	for i, it := range ge.items {
+		fmt.Fprintf(w, "      <node id=\"%d\" label=\"%s\"/>\n", i, xmlEscape(it.Title))
+	}
+	fmt.Fprintln(w, `    </nodes>`)
+	fmt.Fprintln(w, `    <edges>`)
+	n := 0
+	err := ge.edges(func(from int, to int) error {
+		_, err := fmt.Fprintf(w, "      <edge id=\"%d\" source=\"%d\" target=\"%d\"/>\n", n, from, to)
+		n++
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, `    </edges>`)
+	fmt.Fprintln(w, `  </graph>`)
+	_, err = fmt.Fprintln(w, `</gexf>`)
+	return err
+}
+
+func (ge *graphExport) dot(w *bufio.Writer) error {
+	fmt.Fprintln(w, "digraph wikipath {")
+	for i, it := range ge.items {
+		fmt.Fprintf(w, "  n%d [label=%s];\n", i, dotQuote(it.Title))
+	}
+	err := ge.edges(func(from int, to int) error {
+		_, err := fmt.Fprintf(w, "  n%d -> n%d;\n", from, to)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
+
+// matrixMarket writes the adjacency matrix, with a comment per article
+// giving the title for each row and column. Rows and columns count from 1.
+func (ge *graphExport) matrixMarket(w *bufio.Writer) error {
+	// The header needs the number of edges, so count them first.
+	nEdges := 0
+	ge.edges(func(from int, to int) error {
+		nEdges++
+		return nil
+	})
+
+	fmt.Fprintln(w, "%%MatrixMarket matrix coordinate pattern general")
+	for i, it := range ge.items {
+		fmt.Fprintf(w, "%% %d %s\n", i+1, strings.Replace(it.Title, "\n", " ", -1))
+	}
+	fmt.Fprintf(w, "%d %d %d\n", len(ge.items), len(ge.items), nEdges)
+	return ge.edges(func(from int, to int) error {
+		_, err := fmt.Fprintf(w, "%d %d\n", from+1, to+1)
+		return err
+	})
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,95 @@
+package wikipath
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	ind := extractTestIndex()
+	var buf bytes.Buffer
+	if err := ind.ExportGraph(&buf, ExportCSV, ind.Items()); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, buf.String(), "source,target\nA,B\nA,C\nB,A\nB,D\nC,D\nD,E\n")
+}
+
+func TestExportDOT(t *testing.T) {
+	ind := extractTestIndex()
+	ball := ind.Ball([]*IndexItem{ind.Get("C")}, 1)
+	var items []*IndexItem
+	for it := range ball {
+		items = append(items, it)
+	}
+
+	var buf bytes.Buffer
+	if err := ind.ExportGraph(&buf, ExportDOT, items); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, buf.String(), "digraph wikipath {\n  n0 [label=\"C\"];\n  n1 [label=\"D\"];\n  n0 -> n1;\n}\n")
+	assertEqual(t, dotQuote(`Say "hi" \o/`), `"Say \"hi\" \\o/"`)
+}
+
+func TestExportMatrixMarket(t *testing.T) {
+	ind := extractTestIndex()
+	var buf bytes.Buffer
+	if err := ind.ExportGraph(&buf, ExportMatrixMarket, ind.Items()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assertEqual(t, lines[0], "%%MatrixMarket matrix coordinate pattern general")
+	assertEqual(t, lines[1], "% 1 A")
+	assertEqual(t, lines[6], "5 5 6")
+	assertEqual(t, lines[7], "1 2")
+	assertEqual(t, len(lines), 13)
+}
+
+func TestExportXML(t *testing.T) {
+	ind := extractTestIndex()
+	for _, format := range []ExportFormat{ExportGraphML, ExportGEXF} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ind.ExportGraph(&buf, format, ind.Items()); err != nil {
+				t.Fatal(err)
+			}
+
+			// Both formats are well-formed XML with a <node> per article
+			// and an <edge> per link.
+			nodes, edges := 0, 0
+			dec := xml.NewDecoder(&buf)
+			for {
+				tok, err := dec.Token()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				if se, ok := tok.(xml.StartElement); ok {
+					switch se.Name.Local {
+					case "node":
+						nodes++
+					case "edge":
+						edges++
+					}
+				}
+			}
+			assertEqual(t, nodes, 5)
+			assertEqual(t, edges, 6)
+		})
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	f, err := ParseExportFormat("GraphML")
+	assertEqual(t, f, ExportGraphML)
+	assertEqual(t, err, nil)
+
+	_, err = ParseExportFormat("xlsx")
+	if err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
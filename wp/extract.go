@@ -0,0 +1,135 @@
+package wikipath
+
+import (
+	"math/rand"
+)
+
+// Ball returns the articles within `radius` forward links of any of the
+// `seeds`, including the seeds themselves.
+func (ind *Index) Ball(seeds []*IndexItem, radius int) map[*IndexItem]bool {
+	if !ind.ready {
+		ind.Build()
+	}
+
+	ball := make(map[*IndexItem]bool)
+	frontier := make([]*IndexItem, 0, len(seeds))
+	for _, it := range seeds {
+		if !ball[it] {
+			ball[it] = true
+			frontier = append(frontier, it)
+		}
+	}
+
+	for depth := 0; depth < radius && len(frontier) > 0; depth++ {
+		var next []*IndexItem
+		for _, it := range frontier {
+			for _, link := range it.Forward {
+				if !ball[link] {
+					ball[link] = true
+					next = append(next, link)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return ball
+}
+
+// CategorySeeds gets the pages in a category, by its title with or without
+// its namespace, to seed Ball with. Members which aren't pages in the index,
+// like subcategories with no page, are left out. Returns nil if no page is
+// in the category.
+func (ind *Index) CategorySeeds(category string) []*IndexItem {
+	var seeds []*IndexItem
+	for _, member := range ind.Members(category) {
+		if ind.Get(member.Title) == member {
+			seeds = append(seeds, member)
+		}
+	}
+	return seeds
+}
+
+// Sample returns `n` distinct articles from the index chosen with `rng`,
+// or every article if there are fewer than `n`. The same seed gives the
+// same sample from the same index.
+func (ind *Index) Sample(n int, rng *rand.Rand) []*IndexItem {
+	items := ind.Items()
+	if n >= len(items) {
+		return items
+	}
+
+	// Partial Fisher-Yates shuffle.
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(len(items)-i)
+		items[i], items[j] = items[j], items[i]
+	}
+	return items[:n]
+}
+
+// ExtractWpindex copies the articles in `keep` from `r` to `w`, with their
+// links cut down to the ones which lead to other kept articles. Redirects
+// to kept articles are copied too, so the result is a self-contained
+// *.wpindex file. `ind` must be built from the same articles `r` reads.
+//
+// It returns the number of articles and redirects written.
+func ExtractWpindex(ind *Index, keep map[*IndexItem]bool, r *WpindexReader, w *WpindexWriter) (int, error) {
+	if !ind.ready {
+		ind.Build()
+	}
+
+	n := 0
+	for {
+		sa, err := r.ReadArticle()
+		if err == EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		item := ind.Get(sa.Title)
+		if item == nil || !keep[item] {
+			continue
+		}
+
+		if sa.Redirect == "" {
+			if item.Title != sa.Title {
+				continue // a redirect took over this title
+			}
+
+			kept := *sa
+			kept.Links, kept.Edges, kept.Flags = nil, nil, nil
+			kept.Anchors, kept.Contexts = nil, nil
+			kept.Fragments, kept.Sections = nil, nil
+			for i, l := range sa.Links {
+				if dst := ind.Get(l); dst != nil && keep[dst] {
+					kept.Links = append(kept.Links, l)
+					if sa.Edges != nil {
+						kept.Edges = append(kept.Edges, sa.Edge(i))
+					}
+					if sa.Flags != nil {
+						kept.Flags = append(kept.Flags, sa.Flag(i))
+					}
+					if sa.Anchors != nil {
+						kept.Anchors = append(kept.Anchors, sa.Anchors[i])
+					}
+					if sa.Contexts != nil {
+						kept.Contexts = append(kept.Contexts, sa.Context(i))
+					}
+					if sa.Fragments != nil {
+						kept.Fragments = append(kept.Fragments, sa.Fragment(i))
+					}
+					if sa.Sections != nil {
+						kept.Sections = append(kept.Sections, sa.Section(i))
+					}
+				}
+			}
+			sa = &kept
+		}
+
+		if err := w.WriteArticle(sa); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
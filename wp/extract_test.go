@@ -0,0 +1,105 @@
+package wikipath
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+var extractArticles = []*StrippedArticle{
+	{Title: "A", ID: 1, Links: []string{"B", "Redirect to C"}},
+	{Title: "B", ID: 2, Links: []string{"A", "D"}, Sections: []string{"History", ""}},
+	{Title: "C", ID: 3, Links: []string{"D", "Missing"}, Categories: []string{"Category:Letters"}},
+	{Title: "D", ID: 4, Links: []string{"E"}, Categories: []string{"Category:Letters", "Category:Missing letters"}},
+	{Title: "E", ID: 5},
+	{Title: "Redirect to C", ID: 6, Redirect: "C"},
+	{Title: "Redirect to E", ID: 7, Redirect: "E"},
+}
+
+func extractTestIndex() *Index {
+	ind := NewIndex()
+	for _, sa := range extractArticles {
+		ind.AddArticle(sa)
+	}
+	ind.Build()
+	return ind
+}
+
+func titles(items map[*IndexItem]bool) map[string]bool {
+	set := make(map[string]bool)
+	for it := range items {
+		set[it.Title] = true
+	}
+	return set
+}
+
+func TestBall(t *testing.T) {
+	ind := extractTestIndex()
+	seeds := []*IndexItem{ind.Get("A")}
+
+	assertEqual(t, len(ind.Ball(seeds, 0)), 1)
+
+	ball := titles(ind.Ball(seeds, 1))
+	assertEqual(t, len(ball), 3)
+	assertEqual(t, ball["C"], true) // through the redirect
+
+	assertEqual(t, len(ind.Ball(seeds, 2)), 4)
+	assertEqual(t, len(ind.Ball(seeds, 10)), 5)
+}
+
+func TestCategorySeeds(t *testing.T) {
+	ind := extractTestIndex()
+
+	seeds := ind.CategorySeeds("Letters")
+	assertEqual(t, len(seeds), 2)
+	assertEqual(t, seeds[0].Title, "C")
+	assertEqual(t, seeds[1].Title, "D")
+	assertEqual(t, len(ind.CategorySeeds("Category:Letters")), 2)
+	assertEqual(t, len(ind.CategorySeeds("Unknown")), 0)
+
+	ball := titles(ind.Ball(seeds, 1))
+	assertEqual(t, len(ball), 3)
+	assertEqual(t, ball["E"], true)
+}
+
+func TestSample(t *testing.T) {
+	ind := extractTestIndex()
+
+	s1 := ind.Sample(3, rand.New(rand.NewSource(1)))
+	s2 := ind.Sample(3, rand.New(rand.NewSource(1)))
+	assertEqual(t, len(s1), 3)
+	for i := range s1 {
+		assertEqual(t, s1[i], s2[i])
+	}
+
+	assertEqual(t, len(ind.Sample(100, rand.New(rand.NewSource(1)))), 5)
+}
+
+func TestExtractWpindex(t *testing.T) {
+	ind := extractTestIndex()
+	keep := ind.Ball([]*IndexItem{ind.Get("A")}, 1)
+
+	var buf bytes.Buffer
+	r, _ := NewWpindexReader(bytes.NewReader(writeWpindex(t, FormatCompact, extractArticles)))
+	w := NewWpindexWriterFormat(&buf, FormatCompact)
+	n, err := ExtractWpindex(ind, keep, r, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	assertEqual(t, n, 4) // A, B, C and the redirect to C
+
+	articles, _, _ := readWpindex(t, buf.Bytes())
+	byTitle := make(map[string]*StrippedArticle)
+	for _, sa := range articles {
+		byTitle[sa.Title] = sa
+	}
+	assertEqual(t, len(byTitle["B"].Links), 1)
+	assertEqual(t, byTitle["B"].Section(0), "History")
+	assertEqual(t, len(byTitle["C"].Links), 0)
+	assertEqual(t, byTitle["Redirect to C"].Redirect, "C")
+
+	// Everything which is left links somewhere.
+	report, _ := verify(t, buf.Bytes())
+	assertEqual(t, report.Issues[IssueDanglingLink], 0)
+}
@@ -7,3 +7,4 @@ var wikiArchiveBzipPath = flag.String("bzipPath", "./wikis/simple.xml.bz2", "Pat
 var wikiArchiveIndexPath = flag.String("indexPath", "./wikis/simple-index.txt", "Path to the index, as a .txt")
 var WikiArchiveIndexBzipPath = flag.String("bzipIndexPath", "./wikis/simple-index.txt.bz2", "Path to the index, as a .txt.bz2")
 var wpindexPath = flag.String("wpindex", "./wikis/simple.wpindex", "Path to *.wpindex file")
+var update = flag.Bool("update", false, "Update the golden files in testdata.")
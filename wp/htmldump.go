@@ -0,0 +1,209 @@
+package wikipath
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTMLDumpArticle is one line of a Wikimedia Enterprise HTML dump: an
+// article, rendered by Parsoid, and the titles which redirect to it.
+// Only the fields wikipath uses are decoded.
+type HTMLDumpArticle struct {
+	Name       string `json:"name"`
+	Identifier int    `json:"identifier"`
+	Namespace  struct {
+		Identifier int `json:"identifier"`
+	} `json:"namespace"`
+	ArticleBody struct {
+		HTML string `json:"html"`
+	} `json:"article_body"`
+	Redirects []struct {
+		Name string `json:"name"`
+	} `json:"redirects"`
+	Categories []struct {
+		Name string `json:"name"`
+	} `json:"categories"`
+	Version struct {
+		Identifier int `json:"identifier"`
+	} `json:"version"`
+	DateModified string `json:"date_modified"`
+	Description  string `json:"description"`
+}
+
+// htmlDumpBatch is how many lines of an HTML dump are parsed at once.
+const htmlDumpBatch = 256
+
+// isHTMLDump returns true if the start of a file looks like an HTML dump:
+// JSON lines, or a tar file of them. `head` is after any decompression.
+func isHTMLDump(head []byte) bool {
+	if len(head) > 262 && string(head[257:262]) == "ustar" {
+		return true
+	}
+	text := bytes.TrimLeft(head, " \t\r\n")
+	return len(text) > 0 && text[0] == '{'
+}
+
+// htmlLinkTitle gets the title of the article a link in Parsoid's HTML
+// points to. Links to articles are "./Title" or "/wiki/Title".
+func htmlLinkTitle(href string) (string, bool) {
+	p, ok := resolveHTMLLink("wiki/_", href)
+	if !ok || !strings.HasPrefix(p, "wiki/") {
+		return "", false
+	}
+	title := strings.Replace(strings.TrimPrefix(p, "wiki/"), "_", " ", -1)
+	return title, title != ""
+}
+
+// NewHTMLDumpArticle creates a StrippedArticle from a line of an HTML dump,
+// and one for each of its redirects. Links are filtered like `SetLinks`
+// does, so links to files and other wikis, and to pages in namespaces
+// `namespaces` doesn't include, are left out.
+func NewHTMLDumpArticle(a *HTMLDumpArticle, namespaces *NamespaceFilter) []*StrippedArticle {
+	ns := a.Namespace.Identifier
+	sa := &StrippedArticle{Title: a.Name, ID: a.Identifier, Namespace: ns}
+	var links []Link
+	for _, href := range ParseHTMLLinks(a.ArticleBody.HTML) {
+		title, ok := htmlLinkTitle(href)
+		if !ok {
+			continue
+		}
+		if l, ok := DefaultLinkParser.newLink(title); ok {
+			l.Edge = EdgeProse
+			links = append(links, l)
+		}
+	}
+	sa.SetLinks(links, namespaces)
+	sa.DropContext()
+
+	for _, c := range a.Categories {
+		sa.addCategory(c.Name)
+	}
+	sa.Disambiguation = strings.Contains(a.ArticleBody.HTML, "mw:PageProp/disambiguation")
+	sa.RevisionID, sa.Description = a.Version.Identifier, a.Description
+	if t, err := time.Parse(time.RFC3339, a.DateModified); err == nil {
+		sa.Timestamp = t
+	}
+
+	articles := []*StrippedArticle{sa}
+	for _, r := range a.Redirects {
+		// Redirect pages aren't in the dump, so they have no ID.
+		articles = append(articles, &StrippedArticle{Title: r.Name, Redirect: a.Name, Namespace: ns})
+	}
+	return articles
+}
+
+// LoadHTMLDump reads a Wikimedia Enterprise HTML dump, calling `visitor` for
+// each article and redirect in it and stopping if it returns false. The dump
+// can be the *.tar.gz as published, or the NDJSON files in it, gzipped or not.
+//
+// Links come from the `<a href>`s in each article's HTML, so unlike the
+// wikitext in the XML dumps, they include the links templates add. Links to
+// namespaces `namespaces` doesn't include are left out. Lines are parsed in
+// parallel, but visited in the order they're in the dump.
+func LoadHTMLDump(source io.Reader, namespaces *NamespaceFilter, visitor func(*StrippedArticle) bool) error {
+	buf := bufio.NewReaderSize(source, readerBufSize)
+	if head, _ := buf.Peek(2); len(head) == 2 && head[0] == 0x1f && head[1] == 0x8b {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		buf = bufio.NewReaderSize(gz, readerBufSize)
+	}
+
+	if head, _ := buf.Peek(262); len(head) == 262 && string(head[257:262]) == "ustar" {
+		tr := tar.NewReader(buf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg || !strings.HasSuffix(hdr.Name, "json") {
+				continue
+			}
+			if err := loadHTMLDumpLines(bufio.NewReaderSize(tr, readerBufSize), namespaces, visitor); err != nil {
+				return fmt.Errorf("%s: %v", hdr.Name, err)
+			}
+		}
+	}
+
+	return loadHTMLDumpLines(buf, namespaces, visitor)
+}
+
+// loadHTMLDumpLines reads the lines of one NDJSON file of an HTML dump.
+func loadHTMLDumpLines(r *bufio.Reader, namespaces *NamespaceFilter, visitor func(*StrippedArticle) bool) error {
+	nWorkers := runtime.GOMAXPROCS(-1)
+	line := 0
+
+	for {
+		// Read a batch of lines, skipping blank ones but keeping count of
+		// where each is in the file.
+		var batch [][]byte
+		var lines []int
+		var readErr error
+		for len(batch) < htmlDumpBatch {
+			var raw []byte
+			raw, readErr = r.ReadBytes('\n')
+			if len(raw) > 0 {
+				line++
+			}
+			if len(bytes.TrimSpace(raw)) > 0 {
+				batch = append(batch, raw)
+				lines = append(lines, line)
+			}
+			if readErr != nil {
+				break
+			}
+		}
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		// Parse it in parallel.
+		results := make([][]*StrippedArticle, len(batch))
+		errs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for w := 0; w < nWorkers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := w; i < len(batch); i += nWorkers {
+					var a HTMLDumpArticle
+					if err := json.Unmarshal(batch[i], &a); err != nil {
+						errs[i] = err
+						continue
+					}
+					results[i] = NewHTMLDumpArticle(&a, namespaces)
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		// Visit it in order.
+		for i, articles := range results {
+			if errs[i] != nil {
+				return fmt.Errorf("line %d: %v", lines[i], errs[i])
+			}
+			for _, sa := range articles {
+				if !visitor(sa) {
+					return ErrStopped
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package wikipath
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Two lines of an HTML dump, trimmed to the fields that matter.
+const testHTMLDump = `{"name":"Queen (band)","identifier":42010,"namespace":{"identifier":0},` +
+	`"article_body":{"html":"<p><a rel=\"mw:WikiLink\" href=\"./Freddie_Mercury\" title=\"Freddie Mercury\">Freddie</a> ` +
+	`<a rel=\"mw:WikiLink\" href=\"./Brian_May#Life\">Brian</a> <a rel=\"mw:ExtLink\" href=\"https://queenonline.com\">site</a> ` +
+	`<a href=\"./Queen_(band)#Members\">members</a> <a class=\"new\" href=\"./Spike_Edney?action=edit&amp;redlink=1\">Spike</a></p>` +
+	`<table class=\"navbox\"><a rel=\"mw:WikiLink\" href=\"./Roger_Taylor_(Queen_drummer)\">Roger</a></table>` +
+	`<a href=\"./File:Queen_1984.jpg\">photo</a> <a href=\"./Help:IPA\">IPA</a> <a href=\"./Template:Queen\">v</a> ` +
+	`<a href=\"./Category:Queen_(band)\">Queen</a>"},` +
+	`"redirects":[{"name":"Queen band","url":"https://en.wikipedia.org/wiki/Queen_band"}],` +
+	`"categories":[{"name":"Category:Rock bands","url":"https://en.wikipedia.org/wiki/Category:Rock_bands"}]}
+{"name":"Freddie Mercury","identifier":42068,"namespace":{"identifier":0},"article_body":{"html":"<a href=\"/wiki/Queen_(band)\">Queen</a>"}}
+`
+
+var testHTMLDumpArticles = []*StrippedArticle{
+	{Title: "Queen (band)", ID: 42010, Links: []string{"Freddie Mercury", "Brian May", "Queen (band)", "Spike Edney", "Roger Taylor (Queen drummer)"}, Categories: []string{"Category:Queen (band)", "Category:Rock bands"}},
+	{Title: "Queen band", Redirect: "Queen (band)"},
+	{Title: "Freddie Mercury", ID: 42068, Links: []string{"Queen (band)"}},
+}
+
+func loadTestHTMLDump(t *testing.T, data []byte, namespaces *NamespaceFilter) []*StrippedArticle {
+	var articles []*StrippedArticle
+	err := LoadHTMLDump(bytes.NewReader(data), namespaces, func(sa *StrippedArticle) bool {
+		articles = append(articles, sa)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return articles
+}
+
+// tarGzHTMLDump packs NDJSON files into a *.tar.gz, like the published dumps.
+func tarGzHTMLDump(files map[string]string, names ...string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg})
+		tw.Write([]byte(files[name]))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestLoadHTMLDump(t *testing.T) {
+	t.Run("NDJSON", func(t *testing.T) {
+		articles := loadTestHTMLDump(t, []byte(testHTMLDump), nil)
+		if !reflect.DeepEqual(articles, testHTMLDumpArticles) {
+			t.Fatalf("got %+v", articles)
+		}
+	})
+
+	t.Run("TarGz", func(t *testing.T) {
+		lines := strings.SplitAfter(testHTMLDump, "\n")
+		files := map[string]string{
+			"enwiki_namespace_0_0.ndjson": lines[0],
+			"README.txt":                  "not articles",
+			"enwiki_namespace_0_1.ndjson": lines[1],
+		}
+		data := tarGzHTMLDump(files, "enwiki_namespace_0_0.ndjson", "README.txt", "enwiki_namespace_0_1.ndjson")
+
+		articles := loadTestHTMLDump(t, data, nil)
+		if !reflect.DeepEqual(articles, testHTMLDumpArticles) {
+			t.Fatalf("got %+v", articles)
+		}
+
+		kind, err := DetectArchive(bytes.NewReader(data), int64(len(data)))
+		assertEqual(t, err, nil)
+		assertEqual(t, kind, ArchiveHTMLDump)
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+		// Enough lines for a few batches, which must come out in order.
+		line := strings.SplitAfter(testHTMLDump, "\n")[1]
+		var dump strings.Builder
+		n := htmlDumpBatch*2 + 3
+		for i := 0; i < n; i++ {
+			dump.WriteString(strings.Replace(line, "42068", strconv.Itoa(i+1), 1))
+		}
+		articles := loadTestHTMLDump(t, []byte(dump.String()), nil)
+		assertEqual(t, len(articles), n)
+		for i, sa := range articles {
+			assertEqual(t, sa.ID, i+1)
+			assertEqual(t, len(sa.Links), 1)
+		}
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		// Links to included namespaces (12 is Help) are kept, but never files.
+		namespaces := &NamespaceFilter{Include: map[int]bool{NamespaceMain: true, 12: true, NamespaceFile: true}}
+		articles := loadTestHTMLDump(t, []byte(testHTMLDump), namespaces)
+		want := []string{"Freddie Mercury", "Brian May", "Queen (band)", "Spike Edney", "Roger Taylor (Queen drummer)", "Help:IPA"}
+		if !reflect.DeepEqual(articles[0].Links, want) {
+			t.Fatalf("got links %q, want %q", articles[0].Links, want)
+		}
+	})
+
+	t.Run("BadLine", func(t *testing.T) {
+		// Blank lines are skipped, but still counted.
+		err := LoadHTMLDump(strings.NewReader(testHTMLDump+"\n\n{\"name\": \n"), nil, func(*StrippedArticle) bool { return true })
+		if err == nil || !strings.Contains(err.Error(), "line 5") {
+			t.Fatalf("expected an error on line 5, got %v", err)
+		}
+	})
+}
+
+func TestHTMLLinkTitle(t *testing.T) {
+	cases := map[string]string{
+		"./Queen_(band)":       "Queen (band)",
+		"/wiki/AC%2FDC":        "AC/DC",
+		"./Help:Contents#Top":  "Help:Contents",
+		"/w/index.php?title=X": "",
+		"https://example.com":  "",
+		"#cite_note-1":         "",
+	}
+	for href, want := range cases {
+		title, _ := htmlLinkTitle(href)
+		assertEqual(t, title, want)
+	}
+}
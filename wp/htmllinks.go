@@ -0,0 +1,148 @@
+package wikipath
+
+import (
+	"html"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ParseHTMLLinks returns the `href` of every `<a>` tag in some HTML, with
+// entities decoded, in the order they appear.
+//
+// It isn't a full HTML parser, but it skips comments, and copes with
+// attributes in any order and any style of quoting, which is all the
+// rendered articles in ZIM files and HTML dumps need.
+func ParseHTMLLinks(doc string) []string {
+	var links []string
+	for i := 0; i < len(doc); {
+		lt := strings.IndexByte(doc[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+
+		if strings.HasPrefix(doc[i:], "<!--") {
+			end := strings.Index(doc[i:], "-->")
+			if end < 0 {
+				break
+			}
+			i += end + len("-->")
+			continue
+		}
+
+		gt := strings.IndexByte(doc[i:], '>')
+		if gt < 0 {
+			break
+		}
+		tag := doc[i+1 : i+gt]
+		i += gt + 1
+
+		if len(tag) < 2 || (tag[0] != 'a' && tag[0] != 'A') || !isHTMLSpace(tag[1]) {
+			continue
+		}
+		if href, ok := htmlAttr(tag[2:], "href"); ok {
+			links = append(links, href)
+		}
+	}
+	return links
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// htmlAttr finds an attribute in the inside of a tag, after its name.
+func htmlAttr(attrs string, name string) (string, bool) {
+	for i := 0; i < len(attrs); {
+		// Attribute name.
+		for i < len(attrs) && (isHTMLSpace(attrs[i]) || attrs[i] == '/') {
+			i++
+		}
+		start := i
+		for i < len(attrs) && !isHTMLSpace(attrs[i]) && attrs[i] != '=' {
+			i++
+		}
+		key := attrs[start:i]
+		for i < len(attrs) && isHTMLSpace(attrs[i]) {
+			i++
+		}
+
+		// Attribute value, if there is one.
+		var val string
+		if i < len(attrs) && attrs[i] == '=' {
+			i++
+			for i < len(attrs) && isHTMLSpace(attrs[i]) {
+				i++
+			}
+			if i < len(attrs) && (attrs[i] == '"' || attrs[i] == '\'') {
+				quote := attrs[i]
+				end := strings.IndexByte(attrs[i+1:], quote)
+				if end < 0 {
+					end = len(attrs) - i - 1
+				}
+				val = attrs[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(attrs) && !isHTMLSpace(attrs[i]) {
+					i++
+				}
+				val = attrs[start:i]
+			}
+		}
+
+		if strings.EqualFold(key, name) {
+			return html.UnescapeString(val), true
+		}
+		if key == "" {
+			i++
+		}
+	}
+	return "", false
+}
+
+// resolveHTMLLink resolves a relative link from the page at `base` to the
+// path it points at, dropping any query or fragment. Links to other sites,
+// and links to just a fragment of the same page, aren't resolved.
+func resolveHTMLLink(base string, href string) (string, bool) {
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "//") {
+		return "", false
+	}
+	if hasURLScheme(href) {
+		return "", false
+	}
+
+	if end := strings.IndexAny(href, "?#"); end >= 0 {
+		href = href[:end]
+	}
+	unescaped, err := url.PathUnescape(href)
+	if err != nil {
+		return "", false
+	}
+
+	if strings.HasPrefix(unescaped, "/") {
+		return path.Clean(unescaped[1:]), true
+	}
+	return path.Join(path.Dir(base), unescaped), true
+}
+
+// hasURLScheme returns true for links like "https://..." or "mailto:...",
+// but not for titles with a colon in them, like "Help:Contents".
+func hasURLScheme(href string) bool {
+	colon := strings.IndexByte(href, ':')
+	if colon <= 0 {
+		return false
+	}
+	scheme := strings.ToLower(href[:colon])
+	for _, c := range scheme {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	switch scheme {
+	case "mailto", "tel", "javascript", "data", "geo", "news":
+		return true
+	}
+	return strings.HasPrefix(href[colon+1:], "//")
+}
@@ -0,0 +1,40 @@
+package wikipath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHTMLLinks(t *testing.T) {
+	doc := `<p><a href="One">1</a> <abbr title="x">2</abbr> <a
+		class="x" href = 'Two&amp;Three' >3</a> <!-- <a href="Hidden"> -->
+		<a name=anchor>4</a> <a data-x="y" href=Four>5</a> <area href="Not">`
+
+	links := ParseHTMLLinks(doc)
+	assertEqual(t, reflect.DeepEqual(links, []string{"One", "Two&Three", "Four"}), true)
+}
+
+func TestResolveHTMLLink(t *testing.T) {
+	cases := []struct {
+		base, href, path string
+		ok               bool
+	}{
+		{"A/Queen", "Freddie_Mercury", "A/Freddie_Mercury", true},
+		{"A/Queen", "./Brian_May#Life", "A/Brian_May", true},
+		{"A/Queen", "../I/Queen.jpg", "I/Queen.jpg", true},
+		{"A/Queen", "Queen_%28band%29?action=raw", "A/Queen_(band)", true},
+		{"A/Queen", "Help:Contents", "A/Help:Contents", true},
+		{"wiki/Queen", "/wiki/Roger_Taylor", "wiki/Roger_Taylor", true},
+		{"A/Queen", "#History", "", false},
+		{"A/Queen", "https://example.com/", "", false},
+		{"A/Queen", "//example.com/", "", false},
+		{"A/Queen", "mailto:someone@example.com", "", false},
+		{"A/Queen", "bad%zz", "", false},
+	}
+	for _, c := range cases {
+		p, ok := resolveHTMLLink(c.base, c.href)
+		if p != c.path || ok != c.ok {
+			t.Errorf("resolveHTMLLink(%q, %q) = %q, %v; want %q, %v", c.base, c.href, p, ok, c.path, c.ok)
+		}
+	}
+}
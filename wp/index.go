@@ -3,8 +3,11 @@ package wikipath
 import (
 	"math/rand"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // NormalizeArticleTitle normalizes an article title to get a
@@ -18,10 +21,22 @@ func NormalizeArticleTitle(title string) string {
 // If built, each article has a set of forward/reverse pointers.
 type Index struct {
 	itemIndex    map[string]*IndexItem // Map of normalized article title to `Item`s.
+	idIndex      map[int]*IndexItem    // Map of page ID to `Item`s.
 	itemIndexMut sync.RWMutex
 
 	tempLinks  []*StrippedArticle // [empty if ready] Articles to be indexed.
 	tempRedirs []*StrippedArticle // [empty if ready] Redirects to be indexed.
+	tempCats   []*StrippedArticle // [empty if ready] Category pages outside the index, for their parents.
+
+	categoryIndex map[string]*IndexItem // Map of normalized category name, without its prefix, to its `Item`.
+
+	namespaces *NamespaceFilter // Namespaces to index pages from. nil is only articles.
+	language   string           // Language code of the wiki, or "" if it isn't set.
+
+	// Counted by Build, for Stats.
+	redLinks        int64 // Links to pages which aren't in the index.
+	duplicateLinks  int64 // Links to a page an article has already linked to.
+	brokenRedirects int   // Redirects to pages which aren't in the index.
 
 	ready bool // If the index has been built
 }
@@ -30,27 +45,87 @@ type Index struct {
 // If built, it has pointers to articles it links to, and pointers
 // to articles which link to it.
 type IndexItem struct {
-	Title string // Non-normalized title of the page.
+	Title          string // Non-normalized title of the page.
+	ID             int    // ID of the page, or 0 if it isn't known.
+	Namespace      int    // Namespace the page is in, 0 for articles.
+	Disambiguation bool   // If the page is a disambiguation page.
+	Language       string // Language code of the page's wiki, or "" in an index of one wiki.
+
+	RevisionID  int       // ID of the revision the page is from, or 0 if unknown.
+	Timestamp   time.Time // When the revision was made, or zero if unknown.
+	Size        int       // Length of the page's wikitext in bytes, or 0 if unknown.
+	LinkCount   int       // How many links the page has to other pages, counting repeats.
+	Description string    // The page's short description.
+
+	Forward        []*IndexItem   // Items this pages links to.
+	ForwardEdges   []EdgeType     // Types of the links in Forward.
+	ForwardContext []*LinkContext // Where the links in Forward are, or nil if that isn't known.
+	ForwardMut     sync.Mutex
+
+	Reverse      []*IndexItem // Items which link to this one.
+	ReverseEdges []EdgeType   // Types of the links in Reverse.
+	ReverseMut   sync.Mutex
+
+	// FirstLink is the first article linked to from the page's prose, outside
+	// of parentheses, italics and templates, or nil if there isn't one.
+	FirstLink *IndexItem
+
+	Categories []*IndexItem // Categories the page is in.
+	Members    []*IndexItem // For a category, the pages and categories in it.
+
+	Interwiki []InterwikiLink // Links to pages on other wikis, like [[fr:Paris]].
+
+	Sitelinks []*IndexItem // The same page on other languages' wikis, from Wikidata.
+}
+
+// LinkContext is where an article mentions an article it links to.
+type LinkContext struct {
+	Anchor   string // Text of the link.
+	Sentence string // Sentence the link is in.
+	Section  string // Heading of the section the link is in, "" in the lead.
+	Fragment string // Section of the article linked to, after the '#'.
+}
+
+// PathOptions change which paths FindPathWith can find.
+type PathOptions struct {
+	Edges      EdgeType // Types of link the path can follow. Unset, it's EdgeAll.
+	Categories bool     // If the path can go from a page to its category, and from a category to its pages.
+
+	Disambiguation DisambigMode // How the path treats disambiguation pages, other than at its ends.
+}
 
-	Forward    []*IndexItem // Items this pages links to.
-	ForwardMut sync.Mutex
+// follows returns true if a path can follow a link of type `e`.
+func (opts *PathOptions) follows(e EdgeType) bool {
+	return opts.Edges&e != 0
+}
 
-	Reverse    []*IndexItem // Items which link to this one.
-	ReverseMut sync.Mutex
+// withDefaults fills in the options which aren't set.
+func (opts PathOptions) withDefaults() PathOptions {
+	if opts.Edges == 0 {
+		opts.Edges = EdgeAll
+	}
+	return opts
 }
 
 // NewIndex creates an Index.
 func NewIndex() *Index {
 	return &Index{
-		itemIndex:  make(map[string]*IndexItem),
-		tempLinks:  make([]*StrippedArticle, 0),
-		tempRedirs: make([]*StrippedArticle, 0),
+		itemIndex:     make(map[string]*IndexItem),
+		idIndex:       make(map[int]*IndexItem),
+		categoryIndex: make(map[string]*IndexItem),
+		tempLinks:     make([]*StrippedArticle, 0),
+		tempRedirs:    make([]*StrippedArticle, 0),
 	}
 }
 
 // FindPath gets a list of paths between two IndexItems, sorted by length.
 // Returns (path found, items touched).
 func (ind *Index) FindPath(from *IndexItem, to *IndexItem, depth int) (path *IndexPath, searched int) {
+	return ind.FindPathWith(from, to, depth, PathOptions{Edges: EdgeAll})
+}
+
+// FindPathWith is FindPath, only following the links `opts` allows.
+func (ind *Index) FindPathWith(from *IndexItem, to *IndexItem, depth int, opts PathOptions) (path *IndexPath, searched int) {
 	// Idiot check
 	if from == to {
 		return NewIndexPath(from, FORWARD), 0
@@ -62,12 +137,13 @@ func (ind *Index) FindPath(from *IndexItem, to *IndexItem, depth int) (path *Ind
 	}
 
 	// Run the search.
-	path, searched = pathSearch(from, to, depth)
+	opts = opts.withDefaults()
+	path, searched = pathSearch(from, to, depth, &opts)
 
 	return path, searched
 }
 
-func pathSearch(from *IndexItem, to *IndexItem, depth int) (path *IndexPath, searched int) {
+func pathSearch(from *IndexItem, to *IndexItem, depth int, opts *PathOptions) (path *IndexPath, searched int) {
 	// Set up dict of already-visited item paths.
 	found := make(map[*IndexItem]*IndexPath)
 
@@ -88,13 +164,32 @@ func pathSearch(from *IndexItem, to *IndexItem, depth int) (path *IndexPath, sea
 
 		// Get the right link list depending on direction
 		var links []*IndexItem
+		var edges []EdgeType
 		if path.Direction == FORWARD {
-			links = path.Item.Forward
+			links, edges = path.Item.Forward, path.Item.ForwardEdges
 		} else {
-			links = path.Item.Reverse
+			links, edges = path.Item.Reverse, path.Item.ReverseEdges
+		}
+
+		// Pages and their categories go both ways, so they're the same in
+		// either direction. So do sitelinks to other languages.
+		nLinks := len(links)
+		if opts.Categories {
+			links = append(links[:nLinks:nLinks], path.Item.Categories...)
+			links = append(links, path.Item.Members...)
+		}
+		if len(path.Item.Sitelinks) > 0 {
+			links = append(links[:len(links):len(links)], path.Item.Sitelinks...)
 		}
 
-		for _, link := range links {
+		for i, link := range links {
+			if i < nLinks && !opts.follows(edges[i]) {
+				continue
+			}
+			dab := link.Disambiguation && link != from && link != to
+			if dab && opts.Disambiguation == DisambigAvoid {
+				continue
+			}
 			linkPath := path.Append(link)
 			foundPath := found[link]
 
@@ -103,8 +198,13 @@ func pathSearch(from *IndexItem, to *IndexItem, depth int) (path *IndexPath, sea
 				return linkPath, searched
 			} else if foundPath == nil {
 				// Not searched yet. Add this to the queue of pages to be searched.
+				// Free pages are searched before any further ones.
 				found[link] = linkPath
-				queue.Enqueue(linkPath)
+				if dab && opts.Disambiguation == DisambigFree {
+					queue.EnqueueFront(linkPath)
+				} else {
+					queue.Enqueue(linkPath)
+				}
 			} else if foundPath.Direction == linkPath.Direction {
 				// Already searched in the same direction.
 				// If our way is shorter, set the path to our way.
@@ -126,10 +226,20 @@ func pathSearch(from *IndexItem, to *IndexItem, depth int) (path *IndexPath, sea
 // AddArticle adds an article to the index.
 //
 // Index these things:
-// - make an IndexItem, add it to the itemIndex
+// - make an IndexItem, add it to the itemIndex and idIndex
 // - Parse the links from the article text, add it to the linkIndex
 // - Figure out redirects, add them to the redirectIndex.
+//
+// Pages in namespaces the index doesn't include are left out.
 func (ind *Index) AddArticle(a *StrippedArticle) {
+	if !ind.namespaces.Has(a.Namespace) {
+		// Category pages are still needed for their parent categories.
+		if a.Namespace == NamespaceCategory && a.Redirect == "" && len(a.Categories) > 0 {
+			ind.tempCats = append(ind.tempCats, a)
+			ind.ready = false
+		}
+		return
+	}
 	k := NormalizeArticleTitle(a.Title)
 
 	if a.Redirect != "" {
@@ -140,7 +250,22 @@ func (ind *Index) AddArticle(a *StrippedArticle) {
 
 		// Make article if it doesn't already exist.
 		if ind.itemIndex[k] == nil {
-			ind.itemIndex[k] = &IndexItem{Title: a.Title}
+			ind.itemIndex[k] = &IndexItem{
+				Title:          a.Title,
+				ID:             a.ID,
+				Namespace:      a.Namespace,
+				Disambiguation: a.Disambiguation,
+				Language:       ind.language,
+				RevisionID:     a.RevisionID,
+				Timestamp:      a.Timestamp,
+				Size:           a.Size,
+				LinkCount:      len(a.Links),
+				Description:    a.Description,
+				Interwiki:      a.Interwiki,
+			}
+			if a.ID != 0 {
+				ind.idIndex[a.ID] = ind.itemIndex[k]
+			}
 		}
 
 		// Add links to temp link index.
@@ -152,6 +277,33 @@ func (ind *Index) AddArticle(a *StrippedArticle) {
 	ind.ready = false
 }
 
+// SetNamespaces sets which namespaces AddArticle keeps pages from. By
+// default it only keeps articles, from the main namespace.
+func (ind *Index) SetNamespaces(nf *NamespaceFilter) {
+	ind.namespaces = nf
+}
+
+// SetLanguage sets the language code of the wiki the index is of, like
+// "de", for its pages and any added after. It's needed to join wikis in
+// different languages with a MultiIndex.
+func (ind *Index) SetLanguage(lang string) {
+	ind.itemIndexMut.Lock()
+	defer ind.itemIndexMut.Unlock()
+
+	ind.language = lang
+	for _, item := range ind.itemIndex {
+		item.Language = lang
+	}
+	for _, c := range ind.categoryIndex {
+		c.Language = lang
+	}
+}
+
+// Language gets the language code set with SetLanguage.
+func (ind *Index) Language() string {
+	return ind.language
+}
+
 // Build builds the index, finding each article's forward and reverse pointers.
 func (ind *Index) Build() {
 
@@ -174,28 +326,81 @@ func (ind *Index) Build() {
 
 			// tempItem isn't a redirect
 
-			// For each link in the article, add a forward and reverse pointer
-			linkSrc := ind.Get(k) // Get() takes care of locking
-			for _, linkName := range sa.Links {
+			// Find each article linked to once, with every type of link to it.
+			var dsts []*IndexItem
+			var dstEdges []EdgeType
+			var dstContext []*LinkContext
+			seen := make(map[*IndexItem]int)
+			var red, duplicate int64
+			for i, linkName := range sa.Links {
 				linkDst := ind.Get(linkName)
 
 				// Check for broken links.
-				if linkDst != nil {
-					// Append to forward links
-					linkSrc.ForwardMut.Lock()
-					linkSrc.Forward = append(linkSrc.Forward, linkDst)
-					linkSrc.ForwardMut.Unlock()
-
-					// Append to reverse links
-					linkDst.ReverseMut.Lock()
-					linkDst.Reverse = append(linkDst.Reverse, linkSrc)
-					linkDst.ReverseMut.Unlock()
+				if linkDst == nil {
+					red++
+					continue
+				}
+				// Keep where the first link is, or the first in the prose.
+				var ctx *LinkContext
+				if sa.Contexts != nil || sa.Sections != nil || sa.Fragments != nil {
+					ctx = &LinkContext{Anchor: sa.Anchor(i), Sentence: sa.Context(i), Section: sa.Section(i), Fragment: sa.Fragment(i)}
+				}
+				if j, ok := seen[linkDst]; ok {
+					duplicate++
+					if dstEdges[j]&EdgeProse == 0 && sa.Edge(i)&EdgeProse != 0 && ctx != nil {
+						dstContext[j] = ctx
+					}
+					dstEdges[j] |= sa.Edge(i)
+				} else {
+					seen[linkDst] = len(dsts)
+					dsts = append(dsts, linkDst)
+					dstEdges = append(dstEdges, sa.Edge(i))
+					dstContext = append(dstContext, ctx)
+				}
+			}
+
+			atomic.AddInt64(&ind.redLinks, red)
+			atomic.AddInt64(&ind.duplicateLinks, duplicate)
+
+			// For each of them, add a forward and reverse pointer
+			linkSrc := ind.Get(k) // Get() takes care of locking
+			linkSrc.FirstLink = ind.firstLink(linkSrc, sa)
+			for i, linkDst := range dsts {
+				// Append to forward links
+				linkSrc.ForwardMut.Lock()
+				linkSrc.Forward = append(linkSrc.Forward, linkDst)
+				linkSrc.ForwardEdges = append(linkSrc.ForwardEdges, dstEdges[i])
+				if sa.Contexts != nil || sa.Sections != nil || sa.Fragments != nil {
+					linkSrc.ForwardContext = append(linkSrc.ForwardContext, dstContext[i])
 				}
+				linkSrc.ForwardMut.Unlock()
+
+				// Append to reverse links
+				linkDst.ReverseMut.Lock()
+				linkDst.Reverse = append(linkDst.Reverse, linkSrc)
+				linkDst.ReverseEdges = append(linkDst.ReverseEdges, dstEdges[i])
+				linkDst.ReverseMut.Unlock()
 			}
 		}
 		ec.Done()
 	}
 
+	// Index all redirects first, so links to them resolve.
+	for _, sa := range ind.tempRedirs {
+		k := NormalizeArticleTitle(sa.Title)
+		redir := ind.Get(sa.Redirect)
+
+		// Check for broken links.
+		if redir != nil {
+			ind.itemIndex[k] = redir
+			if sa.ID != 0 && ind.idIndex[sa.ID] == nil {
+				ind.idIndex[sa.ID] = redir
+			}
+		} else {
+			ind.brokenRedirects++
+		}
+	}
+
 	linksWait := NewErrorContext()
 
 	// Channel of all the temp items which need indexing.
@@ -211,25 +416,124 @@ func (ind *Index) Build() {
 	// Wait for all link workers to finish indexing.
 	linksWait.Wait()
 
-	// Index all redirects.
-	for _, sa := range ind.tempRedirs {
-		k := NormalizeArticleTitle(sa.Title)
-		redir := ind.Get(sa.Redirect)
-
-		// Check for broken links.
-		if redir != nil {
-			ind.itemIndex[k] = redir
-		}
+	// Put each page in its categories, and each category in its parents.
+	for _, sa := range ind.tempLinks {
+		ind.addCategories(ind.Get(sa.Title), sa.Categories)
+	}
+	for _, sa := range ind.tempCats {
+		ind.addCategories(ind.category(sa.Title), sa.Categories)
 	}
 
 	// Remove temp index, it's unneeded.
 	ind.tempLinks = nil
 	ind.tempRedirs = nil
+	ind.tempCats = nil
 
 	// Index is now ready.
 	ind.ready = true
 }
 
+// categoryKey gets the key of a category in the categoryIndex, from its
+// title, like "Category:Rock music".
+func categoryKey(title string) string {
+	if colon := strings.IndexByte(title, ':'); colon >= 0 {
+		title = title[colon+1:]
+	}
+	return NormalizeArticleTitle(strings.TrimSpace(title))
+}
+
+// category gets the category with the title `title`, creating it if it
+// isn't in the index yet. A category with a page in the index is that page.
+func (ind *Index) category(title string) *IndexItem {
+	k := categoryKey(title)
+	if c := ind.categoryIndex[k]; c != nil {
+		return c
+	}
+	c := ind.Get(title)
+	if c == nil || c.Namespace != NamespaceCategory {
+		c = &IndexItem{Title: title, Namespace: NamespaceCategory, Language: ind.language}
+	}
+	ind.categoryIndex[k] = c
+	return c
+}
+
+// addCategories puts `item` in each of the categories titled `categories`.
+func (ind *Index) addCategories(item *IndexItem, categories []string) {
+	for _, title := range categories {
+		c := ind.category(title)
+		if c == item {
+			continue
+		}
+		item.Categories = append(item.Categories, c)
+		c.Members = append(c.Members, item)
+	}
+}
+
+// Categories gets the categories `item` is in.
+func (ind *Index) Categories(item *IndexItem) []*IndexItem {
+	if !ind.ready {
+		ind.Build()
+	}
+	return item.Categories
+}
+
+// Category gets a category by its title, with or without its namespace,
+// like "Category:Rock music" or "Rock music". Returns nil if no page is in
+// it.
+func (ind *Index) Category(title string) *IndexItem {
+	if !ind.ready {
+		ind.Build()
+	}
+	if c := ind.categoryIndex[NormalizeArticleTitle(strings.TrimSpace(title))]; c != nil {
+		return c
+	}
+	return ind.categoryIndex[categoryKey(title)]
+}
+
+// Members gets the pages and subcategories in a category, by its title,
+// with or without its namespace. Returns nil if no page is in it.
+func (ind *Index) Members(category string) []*IndexItem {
+	if c := ind.Category(category); c != nil {
+		return c.Members
+	}
+	return nil
+}
+
+// firstLink finds the first link in an article which the "Getting to
+// Philosophy" game would follow.
+func (ind *Index) firstLink(item *IndexItem, sa *StrippedArticle) *IndexItem {
+	for i, linkName := range sa.Links {
+		if sa.Edge(i) != EdgeProse || sa.Flag(i) != 0 {
+			continue
+		}
+		if dst := ind.Get(linkName); dst != nil && dst != item {
+			return dst
+		}
+	}
+	return nil
+}
+
+// FollowFirstLink follows the first link of each article, starting from
+// `item`, until it gets to an article with no links or one it's already
+// been to. It returns the articles in order, starting with `item`, and the
+// position in that list of the article the last one links back to, or -1
+// if the last article has no first link.
+func (ind *Index) FollowFirstLink(item *IndexItem) (chain []*IndexItem, loop int) {
+	if !ind.ready {
+		ind.Build()
+	}
+
+	seen := make(map[*IndexItem]int)
+	for it := item; it != nil; it = it.FirstLink {
+		if i, ok := seen[it]; ok {
+			return chain, i
+		}
+		seen[it] = len(chain)
+		chain = append(chain, it)
+	}
+	return chain, -1
+}
+
 // Status gets status of index as (ready, dirty)
 func (ind *Index) Status() bool {
 	return ind.ready
@@ -243,6 +547,14 @@ func (ind *Index) Get(title string) *IndexItem {
 	return ind.itemIndex[k]
 }
 
+// GetByID gets an IndexItem by page ID. The ID of a redirect gets the
+// article it redirects to, once the index is built.
+func (ind *Index) GetByID(id int) *IndexItem {
+	ind.itemIndexMut.RLock()
+	defer ind.itemIndexMut.RUnlock()
+	return ind.idIndex[id]
+}
+
 // GetRandom returns a random item from the index.
 func (ind *Index) GetRandom() *IndexItem {
 	ind.itemIndexMut.RLock()
@@ -261,3 +573,20 @@ func (ind *Index) GetRandom() *IndexItem {
 
 	return nil // should never happen
 }
+
+// Items returns every article in the index once, sorted by title.
+// Redirects are not included.
+func (ind *Index) Items() []*IndexItem {
+	ind.itemIndexMut.RLock()
+	defer ind.itemIndexMut.RUnlock()
+
+	items := make([]*IndexItem, 0, len(ind.itemIndex))
+	for k, item := range ind.itemIndex {
+		// Redirects share their target's item, under a different key.
+		if NormalizeArticleTitle(item.Title) == k {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Title < items[j].Title })
+	return items
+}
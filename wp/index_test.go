@@ -2,6 +2,7 @@ package wikipath
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -98,6 +99,205 @@ func TestIndex(t *testing.T) {
 	})
 }
 
+func TestFindPathWith(t *testing.T) {
+	// The short way to D is through a navbox; the long way is prose.
+	index := NewIndex()
+	for _, a := range []*Article{
+		{Title: "A", Text: "[[B]] {{Navbox|list1=[[D]]}}"},
+		{Title: "B", Text: "[[C]]\n* [[c]] [[A]]"},
+		{Title: "C", Text: "[[D]]"},
+		{Title: "D"},
+	} {
+		index.AddArticle(NewStrippedArticle(a))
+	}
+	index.Build()
+
+	b := index.Get("B")
+	assertEqual(t, len(b.Forward), 2) // the repeated link is merged
+	assertEqual(t, b.ForwardEdges[0], EdgeProse|EdgeList)
+
+	path, _ := index.FindPath(index.Get("A"), index.Get("D"), 20)
+	assertEqual(t, path.String(), "A > D")
+
+	path, _ = index.FindPathWith(index.Get("A"), index.Get("D"), 20, PathOptions{Edges: EdgeProse})
+	assertEqual(t, path.String(), "A > B > C > D")
+
+	path, _ = index.FindPathWith(index.Get("B"), index.Get("A"), 20, PathOptions{Edges: EdgeNavbox})
+	if path != nil {
+		t.Fatalf("found %v, with no links back to A", path)
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	index := NewIndex()
+	for _, sa := range testArticles {
+		index.AddArticle(sa)
+	}
+	index.Build()
+
+	assertEqual(t, index.GetByID(2).Title, "B")
+	assertEqual(t, index.GetByID(-6).Title, "Négatif")
+	assertEqual(t, index.GetByID(5), index.Get("B")) // E redirects to B
+	assertEqual(t, index.Get("B").ID, 2)
+	assertEqual(t, index.GetByID(7) == nil, true) // Portal:A isn't an article
+	assertEqual(t, index.GetByID(0) == nil, true)
+}
+
+func TestIndexNamespaces(t *testing.T) {
+	articles := []*Article{
+		{Title: "Rock", Text: "[[Music]]"},
+		{Title: "Music", Text: "[[Rock]] [[WP:Music]]"},
+		{Title: "Portal:Music", Namespace: 100, Text: "[[Rock]]"},
+		{Title: "Wikipedia:Music", Namespace: 4, Text: "[[Portal:Music]]"},
+	}
+
+	// Only articles, by default.
+	index := NewIndex()
+	for _, a := range articles {
+		index.AddArticle(DefaultLinkParser.Strip(a, nil))
+	}
+	index.Build()
+	assertEqual(t, index.Get("Portal:Music") == nil, true)
+	assertEqual(t, len(index.Get("Music").Forward), 1)
+
+	nf, err := ParseNamespaces("main,portal,project", DefaultLinkParser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index = NewIndex()
+	index.SetNamespaces(nf)
+	for _, a := range articles {
+		index.AddArticle(DefaultLinkParser.Strip(a, nf))
+	}
+	index.Build()
+	portal := index.Get("Portal:Music")
+	assertEqual(t, portal.Namespace, 100)
+	assertEqual(t, len(index.Get("Music").Forward), 2) // [[WP:Music]] is Wikipedia:Music
+
+	path, _ := index.FindPath(index.Get("Music"), portal, 5)
+	assertEqual(t, path.String(), "Music > Wikipedia:Music > Portal:Music")
+}
+
+func TestIndexCategories(t *testing.T) {
+	index := NewIndex()
+	for _, a := range []*Article{
+		{Title: "Queen", Text: "[[Freddie Mercury]] [[Category:Rock bands]] [[Category:Rock bands]]"},
+		{Title: "Freddie Mercury", Text: "[[Queen]]"},
+		{Title: "Led Zeppelin", Text: "[[Category:Rock bands|Zeppelin]] [[Category:Heavy metal]]"},
+		{Title: "Category:Rock bands", Namespace: NamespaceCategory, Text: "[[Category:Rock music]]"},
+	} {
+		index.AddArticle(NewStrippedArticle(a))
+	}
+	index.Build()
+
+	queen := index.Get("Queen")
+	assertEqual(t, len(index.Categories(queen)), 1)
+	assertEqual(t, index.Categories(queen)[0].Title, "Category:Rock bands")
+
+	bands := index.Category("rock bands")
+	assertEqual(t, bands, index.Category("Category:Rock bands"))
+	assertEqual(t, len(index.Members("Rock bands")), 2)
+	assertEqual(t, index.Categories(bands)[0].Title, "Category:Rock music")
+	assertEqual(t, index.Members("Rock music")[0], bands)
+	assertEqual(t, index.Get("Category:Rock bands") == nil, true)
+	assertEqual(t, index.Members("Pop music") == nil, true)
+
+	mercury, zeppelin := index.Get("Freddie Mercury"), index.Get("Led Zeppelin")
+	path, _ := index.FindPath(mercury, zeppelin, 10)
+	if path != nil {
+		t.Fatalf("found %v, with no links to Led Zeppelin", path)
+	}
+	path, _ = index.FindPathWith(mercury, zeppelin, 10, PathOptions{Categories: true})
+	assertEqual(t, path.String(), "Freddie Mercury > Queen > Category:Rock bands > Led Zeppelin")
+	path, _ = index.FindPathWith(zeppelin, mercury, 10, PathOptions{Categories: true})
+	assertEqual(t, path.String(), "Led Zeppelin > Category:Rock bands > Queen > Freddie Mercury")
+}
+
+func TestFollowFirstLink(t *testing.T) {
+	index := NewIndex()
+	for _, a := range []*Article{
+		{Title: "Queen", Text: "{{Infobox band|genre=[[Opera]]}} ''[[Queen II]]'' ([[Band]]) [[Rock]], [[Music]]."},
+		{Title: "Rock", Text: "[[Rock]] is a [[genre]] of [[Music]]."},
+		{Title: "Genre", Text: "{{Main|Art}} A [[Category (philosophy)|category]] of [[Art]]."},
+		{Title: "Category (philosophy)", Text: "See [[Philosophy]]."},
+		{Title: "Philosophy", Text: "The study of [[knowledge]]."},
+		{Title: "Knowledge", Text: "[[Philosophy|Thought]] about things."},
+		{Title: "Band", Text: "No links."},
+		{Title: "Art"}, {Title: "Music"}, {Title: "Opera"}, {Title: "Queen II"},
+	} {
+		index.AddArticle(NewStrippedArticle(a))
+	}
+
+	chain, loop := index.FollowFirstLink(index.Get("Queen"))
+	titles := make([]string, len(chain))
+	for i, it := range chain {
+		titles[i] = it.Title
+	}
+	assertEqual(t, strings.Join(titles, " > "), "Queen > Rock > Genre > Category (philosophy) > Philosophy > Knowledge")
+	assertEqual(t, loop, 4)
+
+	chain, loop = index.FollowFirstLink(index.Get("Band"))
+	assertEqual(t, len(chain), 1)
+	assertEqual(t, loop, -1)
+}
+
+func TestPathHops(t *testing.T) {
+	index := NewIndex()
+	for _, a := range []*Article{
+		{Title: "Queen", Text: "{{Infobox band|origin=[[London]]}}\n'''Queen''' formed in [[London]]. They played [[Live Aid|a concert]]."},
+		{Title: "London", Text: "Capital of [[England]]."},
+		{Title: "England"},
+	} {
+		index.AddArticle(NewStrippedArticle(a))
+	}
+	index.Build()
+
+	path, _ := index.FindPath(index.Get("Queen"), index.Get("England"), 20)
+	hops := path.Hops()
+	assertEqual(t, len(hops), 2)
+	assertEqual(t, hops[0].From.Title, "Queen")
+	assertEqual(t, hops[0].To.Title, "London")
+	assertEqual(t, *hops[0].Context, LinkContext{Anchor: "London", Sentence: "Queen formed in London."})
+	assertEqual(t, *hops[1].Context, LinkContext{Anchor: "England", Sentence: "Capital of England."})
+
+	// Without contexts, the hops are still there.
+	sa := NewStrippedArticle(&Article{Title: "A", Text: "[[B]]"})
+	sa.DropContext()
+	index = NewIndex()
+	index.AddArticle(sa)
+	index.AddArticle(&StrippedArticle{Title: "B"})
+	path, _ = index.FindPath(index.Get("A"), index.Get("B"), 20)
+	hops = path.Hops()
+	assertEqual(t, len(hops), 1)
+	assertEqual(t, hops[0].Context == nil, true)
+}
+
+func TestParseEdgeTypes(t *testing.T) {
+	cases := map[string]EdgeType{
+		"prose":            EdgeProse,
+		"Prose, see-also":  EdgeProse | EdgeSeeAlso,
+		"all":              EdgeAll,
+		"-infobox,-navbox": EdgeProse | EdgeSeeAlso | EdgeList | EdgeTable,
+		"all,-table":       EdgeAll &^ EdgeTable,
+	}
+	for list, want := range cases {
+		got, err := ParseEdgeTypes(list)
+		assertEqual(t, err, nil)
+		assertEqual(t, got, want)
+	}
+
+	_, err := ParseEdgeTypes("prose,footnote")
+	if err == nil {
+		t.Fatal("expected an error for an unknown edge type")
+	}
+	for _, list := range []string{"-all", "prose,-prose", ""} {
+		if _, err := ParseEdgeTypes(list); err == nil {
+			t.Fatalf("expected an error for '%s', which leaves out every type", list)
+		}
+	}
+	assertEqual(t, (EdgeProse | EdgeNavbox).String(), "prose,navbox")
+}
+
 func die(b *testing.B, err error, msg string, args ...interface{}) {
 	if err != nil {
 		b.Logf(msg, args...)
@@ -100,15 +100,67 @@ func (path *IndexPath) Len() int {
 	return n
 }
 
-// String converts the IndexPath to a string.
+// String converts the IndexPath to a string. Switching to another
+// language is shown with "=" rather than ">". Where it's known, each
+// article is shown with the section of it the path goes through, like
+// "A > B#History > C": the one it links to the next article from, or
+// else the one the last article linked to.
 func (path *IndexPath) String() string {
 	items := path.ToSlice()
 	str := ""
 	for i, it := range items {
-		if i != 0 {
+		if i != 0 && items[i-1].Language != it.Language {
+			str += " = "
+		} else if i != 0 {
 			str += " > "
 		}
-		str += it.Title
+
+		heading := ""
+		if i+1 < len(items) {
+			if ctx := it.linkContext(items[i+1]); ctx != nil {
+				heading = ctx.Section
+			}
+		}
+		if heading == "" && i > 0 {
+			if ctx := items[i-1].linkContext(it); ctx != nil {
+				heading = ctx.Fragment
+			}
+		}
+		str += sectionTitle(it.FullTitle(), heading)
 	}
 	return str
 }
+
+// PathHop is one link in an IndexPath.
+type PathHop struct {
+	From    *IndexItem
+	To      *IndexItem
+	Context *LinkContext // Where From links to To, or nil if that isn't known.
+	Switch  bool         // If To is the same page as From, in another language.
+}
+
+// Hops returns each link in the path, in order, with where each article
+// mentions the next.
+func (path *IndexPath) Hops() []PathHop {
+	items := path.ToSlice()
+	hops := make([]PathHop, 0, len(items)-1)
+	for i := 1; i < len(items); i++ {
+		hops = append(hops, PathHop{
+			From:    items[i-1],
+			To:      items[i],
+			Context: items[i-1].linkContext(items[i]),
+			Switch:  items[i-1].Language != items[i].Language,
+		})
+	}
+	return hops
+}
+
+// linkContext finds where an item links to `dst`.
+func (it *IndexItem) linkContext(dst *IndexItem) *LinkContext {
+	for i, fwd := range it.Forward {
+		if fwd == dst && i < len(it.ForwardContext) {
+			return it.ForwardContext[i]
+		}
+	}
+	return nil
+}
@@ -19,6 +19,12 @@ func (pq *PathQueue) Enqueue(path *IndexPath) {
 	pq.q.PushBack(path)
 }
 
+// EnqueueFront pushes an item to the front of the `PathQueue`, so it's the
+// next one dequeued.
+func (pq *PathQueue) EnqueueFront(path *IndexPath) {
+	pq.q.PushFront(path)
+}
+
 // Dequeue pops an item from the `PathQueue`.
 func (pq *PathQueue) Dequeue() *IndexPath {
 	item := pq.q.Front()
@@ -0,0 +1,286 @@
+package wikipath
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxContext is the most bytes of a link's sentence kept as its context.
+const maxContext = 300
+
+// context gets the sentence around the link at text[offset], as plain text.
+// Sentences can run over lines within a paragraph, but not past a blank
+// line, so the one for a link in a list or a template parameter is the rest
+// of its line.
+func (ls *linkScan) context(offset int) string {
+	if offset >= ls.ctxStart && offset < ls.ctxEnd {
+		return ls.ctx
+	}
+
+	text := ls.text
+	blockStart, blockEnd := ls.paragraph(offset)
+
+	start, end := blockStart, blockEnd
+	for _, stop := range sentenceEnds(text[blockStart:blockEnd]) {
+		if blockStart+stop <= offset {
+			start = blockStart + stop
+		} else {
+			end = blockStart + stop
+			break
+		}
+	}
+
+	// Leave out the name of a template parameter, as in "| genre = [[Rock]]".
+	sentence := text[start:end]
+	if p := strings.TrimSpace(sentence); start == blockStart && strings.HasPrefix(p, "|") {
+		if eq := strings.IndexByte(p, '='); eq > 0 && !strings.ContainsAny(p[:eq], "[{") {
+			sentence = p[eq+1:]
+		}
+	}
+
+	ls.ctxStart, ls.ctxEnd = start, end
+	ls.ctx = clipContext(ls.lp.plainText(sentence))
+	return ls.ctx
+}
+
+// paragraph finds the lines of prose around text[offset], which run until a
+// blank line or a line which isn't prose. Other lines, like list items,
+// table rows, headings and template parameters, are each on their own.
+func (ls *linkScan) paragraph(offset int) (start int, end int) {
+	text := ls.text
+	start = strings.LastIndexByte(text[:offset], '\n') + 1
+	end = len(text)
+	if nl := strings.IndexByte(text[offset:], '\n'); nl >= 0 {
+		end = offset + nl
+	}
+	if !ls.lp.proseLine(text[start:end]) {
+		return start, end
+	}
+
+	for start > 0 {
+		prev := strings.LastIndexByte(text[:start-1], '\n') + 1
+		if !ls.lp.proseLine(text[prev : start-1]) {
+			break
+		}
+		start = prev
+	}
+	for end < len(text) {
+		next := len(text)
+		if nl := strings.IndexByte(text[end+1:], '\n'); nl >= 0 {
+			next = end + 1 + nl
+		}
+		if !ls.lp.proseLine(text[end+1 : next]) {
+			break
+		}
+		end = next
+	}
+	return start, end
+}
+
+// proseLine returns true if a line of wikitext is part of a paragraph, and
+// not blank, a list item, a table row, a heading, preformatted, part of a
+// template split over lines, or an image or category on its own line.
+func (lp *LinkParser) proseLine(line string) bool {
+	if strings.TrimSpace(line) == "" || strings.ContainsRune("*#:;|!={} \t", rune(line[0])) {
+		return false
+	}
+	if strings.HasPrefix(line, "[[") {
+		target := line[2:]
+		if end := strings.IndexAny(target, "|]"); end >= 0 {
+			target = target[:end]
+		}
+		if l, ok := lp.newLink(target); ok && (l.Kind == LinkFile || l.Kind == LinkCategory) {
+			return false
+		}
+	}
+	return true
+}
+
+// sentenceEnds finds where each sentence in some wikitext ends: after a '.',
+// '!' or '?' followed by a space or a line break, as long as it isn't inside
+// a link, a template, a tag, or a comment.
+func sentenceEnds(line string) []int {
+	var ends []int
+	depth := 0
+	for i := 0; i < len(line); i++ {
+		switch {
+		case strings.HasPrefix(line[i:], "<!--"):
+			end := strings.Index(line[i:], "-->")
+			if end < 0 {
+				return ends
+			}
+			i += end + 2
+		case strings.HasPrefix(line[i:], "<ref") && !strings.HasPrefix(line[i:], "<references"):
+			end := strings.Index(line[i:], "</ref>")
+			if gt := strings.IndexByte(line[i:], '>'); gt > 0 && line[i+gt-1] == '/' {
+				end = gt - len("</ref>") + 1 // <ref name="x" />
+			}
+			if end < 0 {
+				return ends
+			}
+			i += end + len("</ref>") - 1
+		case strings.HasPrefix(line[i:], "[[") || strings.HasPrefix(line[i:], "{{"):
+			depth++
+			i++
+		case strings.HasPrefix(line[i:], "]]") || strings.HasPrefix(line[i:], "}}"):
+			if depth > 0 {
+				depth--
+			}
+			i++
+		case depth == 0 && (line[i] == '.' || line[i] == '!' || line[i] == '?'):
+			if i+1 < len(line) && (line[i+1] == ' ' || line[i+1] == '\n') {
+				ends = append(ends, i+1)
+			}
+		}
+	}
+	return ends
+}
+
+// plainText renders some wikitext roughly as it's shown: links become their
+// text, and templates, references, comments and formatting are dropped.
+func (lp *LinkParser) plainText(wikitext string) string {
+	var b strings.Builder
+	for i := 0; i < len(wikitext); {
+		next := strings.IndexAny(wikitext[i:], "<[{'")
+		if next < 0 {
+			b.WriteString(wikitext[i:])
+			break
+		}
+		b.WriteString(wikitext[i : i+next])
+		i += next
+
+		switch {
+		case strings.HasPrefix(wikitext[i:], "<!--"):
+			end := strings.Index(wikitext[i:], "-->")
+			if end < 0 {
+				i = len(wikitext)
+			} else {
+				i += end + len("-->")
+			}
+
+		case wikitext[i] == '<':
+			// Leave out tags, and the contents of ones like <ref>.
+			ls := &linkScan{lp: lp, text: wikitext}
+			gt := strings.IndexByte(wikitext[i:], '>')
+			isTag := gt > 1 && (isASCIILetter(wikitext[i+1]) || wikitext[i+1] == '/')
+			switch skip := ls.skipTag(i, len(wikitext)); {
+			case skip > i+1:
+				i = skip
+			case isTag:
+				i += gt + 1
+			default:
+				b.WriteByte('<')
+				i++
+			}
+
+		case strings.HasPrefix(wikitext[i:], "[["):
+			end := linkEnd(wikitext, i)
+			if end < 0 {
+				b.WriteString("[[")
+				i += 2
+				continue
+			}
+			b.WriteString(lp.linkText(wikitext[i+2 : end-2]))
+			i = end
+
+		case wikitext[i] == '[':
+			// An external link, [http://example.com text].
+			end := strings.IndexByte(wikitext[i:], ']')
+			if end < 0 || !strings.Contains(wikitext[i:i+end], "://") {
+				b.WriteByte('[')
+				i++
+				continue
+			}
+			if space := strings.IndexByte(wikitext[i:i+end], ' '); space > 0 {
+				b.WriteString(wikitext[i+space+1 : i+end])
+			}
+			i += end + 1
+
+		case strings.HasPrefix(wikitext[i:], "{{{"):
+			// A template parameter, which has no value outside the template.
+			if end := strings.Index(wikitext[i:], "}}}"); end >= 0 {
+				i += end + len("}}}")
+			} else {
+				i = len(wikitext)
+			}
+
+		case strings.HasPrefix(wikitext[i:], "{{"):
+			i = templateEnd(wikitext, i)
+
+		case wikitext[i] == '\'':
+			n := 1
+			for i+n < len(wikitext) && wikitext[i+n] == '\'' {
+				n++
+			}
+			if n == 1 {
+				b.WriteByte('\'')
+			}
+			i += n
+
+		default:
+			b.WriteByte(wikitext[i])
+			i++
+		}
+	}
+
+	text := strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+	return strings.TrimLeft(text, "*#:;|=! ")
+}
+
+// linkText gets the text a link is shown as, from the inside of its
+// brackets. Files and categories aren't shown in the text.
+func (lp *LinkParser) linkText(inner string) string {
+	target, anchor, piped := inner, "", false
+	if pipe := strings.IndexByte(inner, '|'); pipe >= 0 {
+		target, anchor, piped = inner[:pipe], inner[pipe+1:], true
+	}
+	l, ok := lp.newLink(target)
+	switch {
+	case !ok:
+		return "[[" + inner + "]]"
+	case l.Kind == LinkFile || l.Kind == LinkCategory:
+		return ""
+	case piped && strings.TrimSpace(anchor) == "":
+		return pipeTrick(l.Target)
+	case piped:
+		return lp.plainText(anchor)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(target), ":")
+}
+
+// templateEnd finds the end of the template starting at text[i], or the
+// end of the text if it isn't closed.
+func templateEnd(text string, i int) int {
+	depth := 0
+	for j := i; j < len(text)-1; j++ {
+		if text[j] == '{' && text[j+1] == '{' {
+			depth++
+			j++
+		} else if text[j] == '}' && text[j+1] == '}' {
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(text)
+}
+
+// clipContext shortens a context to maxContext bytes, on a rune boundary.
+func clipContext(s string) string {
+	return clipText(s, maxContext)
+}
+
+// clipText shortens some text to `n` bytes, on a rune boundary.
+func clipText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	end := n
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "…"
+}
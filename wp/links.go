@@ -0,0 +1,748 @@
+package wikipath
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LinkKind is the kind of page a wikitext link points to.
+type LinkKind int
+
+const (
+	LinkArticle   LinkKind = iota // An article, in the main namespace.
+	LinkCategory                  // [[Category:X]], putting the page in a category.
+	LinkFile                      // [[File:X]], embedding an image or other file.
+	LinkNamespace                 // A page in some other namespace, or [[:Category:X]].
+	LinkInterwiki                 // A page on another wiki, like [[fr:Paris]] or [[wikt:word]].
+)
+
+func (k LinkKind) String() string {
+	switch k {
+	case LinkArticle:
+		return "article"
+	case LinkCategory:
+		return "category"
+	case LinkFile:
+		return "file"
+	case LinkNamespace:
+		return "namespace"
+	case LinkInterwiki:
+		return "interwiki"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText writes the kind's name, for golden files and the API.
+func (k LinkKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// LinkFlag is a set of facts about where a link is, used to find the first
+// link of an article as the "Getting to Philosophy" game does.
+type LinkFlag uint8
+
+const (
+	LinkParens   LinkFlag = 1 << iota // In parentheses.
+	LinkItalic                        // In ''italics''.
+	LinkTemplate                      // Inside a template.
+)
+
+var linkFlagNames = []string{"parens", "italic", "template"}
+
+// String gives the names of the flags in a set, joined with commas.
+func (f LinkFlag) String() string {
+	var names []string
+	for i, name := range linkFlagNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// MarshalText writes the flags' names, for golden files and the API.
+func (f LinkFlag) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// Namespace numbers, as used by MediaWiki.
+const (
+	NamespaceMedia    = -2
+	NamespaceSpecial  = -1
+	NamespaceMain     = 0
+	NamespaceFile     = 6
+	NamespaceTemplate = 10
+	NamespaceCategory = 14
+)
+
+// Link is a link in an article's wikitext.
+type Link struct {
+	Target    string   `json:"target"`             // Title linked to, without any prefix.
+	Fragment  string   `json:"fragment,omitempty"` // Section of the target, after the '#'.
+	Anchor    string   `json:"anchor,omitempty"`   // Text the link is shown as.
+	Offset    int      `json:"offset"`             // Byte offset of the link in the wikitext.
+	Kind      LinkKind `json:"kind"`
+	Prefix    string   `json:"prefix,omitempty"`    // Namespace or interwiki prefix, if any.
+	Namespace int      `json:"namespace,omitempty"` // Namespace of the target, on this wiki.
+	Template  string   `json:"template,omitempty"`  // Innermost template the link is in, if any.
+	Edge      EdgeType `json:"edge"`                // Part of the article the link is in.
+	Flags     LinkFlag `json:"flags,omitempty"`
+	Context   string   `json:"context,omitempty"` // Sentence the link is in, as plain text.
+	Section   string   `json:"section,omitempty"` // Heading of the section the link is in, "" in the lead.
+}
+
+// Title returns the full title of the page linked to, with its namespace.
+func (l *Link) Title() string {
+	if l.Prefix == "" {
+		return l.Target
+	}
+	return l.Prefix + ":" + l.Target
+}
+
+// LinkParser finds the links in wikitext. Its zero value knows no
+// namespaces or interwiki prefixes, so use NewLinkParser.
+type LinkParser struct {
+	// Namespaces maps lowercase namespace names and aliases to their number.
+	Namespaces map[string]int
+
+	// NamespaceNames are the names of each namespace, as used in titles.
+	// Links with an alias like [[WP:Foo]] are given these names instead.
+	NamespaceNames map[int]string
+
+	// Interwiki holds the lowercase prefixes of other wikis and languages.
+	Interwiki map[string]bool
+
+	// LinkTemplates are templates whose unnamed parameters are titles they
+	// link to, like {{Main|Article}}. Names are lowercase.
+	LinkTemplates map[string]bool
+
+	// EdgeTemplates give the EdgeType of links inside templates which
+	// can't be told apart by their names, like {{Taxobox}}. Names are
+	// lowercase. Templates starting with "infobox" are infoboxes, and ones
+	// starting with "navbox" or ending in "sidebar" are navboxes.
+	EdgeTemplates map[string]EdgeType
+
+	// SkipTags are tags whose contents aren't rendered as wikitext, so have
+	// no links. Names are lowercase.
+	SkipTags map[string]bool
+
+	// DisambigTemplates are templates which make a page a disambiguation
+	// page. Names are lowercase. Templates ending in " disambiguation", like
+	// {{Human name disambiguation}}, are too.
+	DisambigTemplates map[string]bool
+}
+
+// defaultNamespaces are the namespaces of English Wikipedia.
+var defaultNamespaces = map[string]int{
+	"media": -2, "special": -1,
+	"talk": 1, "user": 2, "user talk": 3,
+	"wikipedia": 4, "project": 4, "wp": 4, "wikipedia talk": 5, "project talk": 5, "wt": 5,
+	"file": 6, "image": 6, "file talk": 7, "image talk": 7,
+	"mediawiki": 8, "mediawiki talk": 9,
+	"template": 10, "template talk": 11,
+	"help": 12, "help talk": 13,
+	"category": 14, "category talk": 15,
+	"portal": 100, "portal talk": 101,
+	"draft": 118, "draft talk": 119,
+	"timedtext": 710, "timedtext talk": 711,
+	"module": 828, "module talk": 829,
+}
+
+// defaultNamespaceNames are the names of the namespaces of English Wikipedia.
+var defaultNamespaceNames = map[int]string{
+	-2: "Media", -1: "Special",
+	1: "Talk", 2: "User", 3: "User talk",
+	4: "Wikipedia", 5: "Wikipedia talk",
+	6: "File", 7: "File talk",
+	8: "MediaWiki", 9: "MediaWiki talk",
+	10: "Template", 11: "Template talk",
+	12: "Help", 13: "Help talk",
+	14: "Category", 15: "Category talk",
+	100: "Portal", 101: "Portal talk",
+	118: "Draft", 119: "Draft talk",
+	710: "TimedText", 711: "TimedText talk",
+	828: "Module", 829: "Module talk",
+}
+
+// defaultInterwiki are the sister projects, and the larger language editions.
+var defaultInterwiki = []string{
+	"wikipedia", "w", "wiktionary", "wikt", "wikinews", "n", "wikibooks", "b",
+	"wikiquote", "q", "wikisource", "s", "wikispecies", "species", "wikiversity", "v",
+	"wikivoyage", "voy", "wikimedia", "foundation", "wmf", "commons", "c", "meta", "m",
+	"wikidata", "d", "mediawikiwiki", "mw", "phabricator", "phab",
+	"ar", "arz", "ast", "az", "be", "bg", "bn", "ca", "ce", "ceb", "cs", "cy", "da", "de",
+	"el", "en", "eo", "es", "et", "eu", "fa", "fi", "fr", "ga", "gl", "he", "hi", "hr", "hu",
+	"hy", "id", "it", "ja", "ka", "kk", "ko", "la", "lt", "lv", "min", "mk", "ms", "nl", "nn",
+	"no", "pl", "pt", "ro", "ru", "sh", "simple", "sk", "sl", "sr", "sv", "ta", "tg", "th",
+	"tr", "tt", "uk", "ur", "uz", "vi", "war", "zh", "zh-yue", "yue",
+}
+
+// NewLinkParser creates a LinkParser for English Wikipedia.
+func NewLinkParser() *LinkParser {
+	lp := &LinkParser{
+		Namespaces:     make(map[string]int),
+		NamespaceNames: make(map[int]string),
+		Interwiki:      make(map[string]bool),
+		LinkTemplates:  map[string]bool{"main": true, "see also": true, "further": true, "details": true},
+		EdgeTemplates: map[string]EdgeType{
+			"taxobox": EdgeInfobox, "automatic taxobox": EdgeInfobox, "speciesbox": EdgeInfobox,
+			"chembox": EdgeInfobox, "drugbox": EdgeInfobox, "geobox": EdgeInfobox,
+			"campaignbox": EdgeNavbox, "portal bar": EdgeNavbox, "succession box": EdgeNavbox,
+			"see also": EdgeSeeAlso,
+		},
+		SkipTags: map[string]bool{
+			"nowiki": true, "pre": true, "ref": true, "math": true, "chem": true,
+			"syntaxhighlight": true, "source": true, "score": true, "timeline": true,
+			"templatedata": true, "graph": true,
+		},
+		DisambigTemplates: map[string]bool{
+			"disambiguation": true, "disambig": true, "disamb": true, "dab": true, "dis": true,
+			"hndis": true, "geodis": true, "mathdab": true, "numberdis": true, "letter disambiguation": true,
+		},
+	}
+	for name, ns := range defaultNamespaces {
+		lp.Namespaces[name] = ns
+	}
+	for ns, name := range defaultNamespaceNames {
+		lp.NamespaceNames[ns] = name
+	}
+	for _, prefix := range defaultInterwiki {
+		lp.Interwiki[prefix] = true
+	}
+	return lp
+}
+
+// DefaultLinkParser is the LinkParser ParseLinks uses.
+var DefaultLinkParser = NewLinkParser()
+
+// ParseLinks returns the links in some wikitext, in the order they appear.
+func ParseLinks(text string) []Link {
+	return DefaultLinkParser.Parse(text)
+}
+
+// linkScan is the state of one Parse.
+type linkScan struct {
+	lp        *LinkParser
+	text      string
+	links     []Link
+	templates []string // Names of the templates the scan is inside, innermost last.
+
+	section string // Heading of the section the scan is in, as plain text.
+	seeAlso bool   // If the scan is in the "See also" section.
+	parens  int    // How many parentheses the line being scanned is inside.
+	italic  bool   // If the line being scanned is in italics.
+	list    bool   // If the line being scanned is part of a list.
+	tables  int    // How many tables the scan is inside.
+
+	ctxStart, ctxEnd int    // Bounds of the last sentence a context was made for.
+	ctx              string // Its context.
+}
+
+// Parse returns the links in some wikitext, in the order they appear.
+//
+// Comments and the contents of tags like <nowiki>, <pre> and <ref> are
+// skipped. Links inside template parameters are found, and so are the
+// titles given to templates like {{Main}}, which turn them into links.
+func (lp *LinkParser) Parse(text string) []Link {
+	ls := &linkScan{lp: lp, text: text}
+	ls.line(0)
+	ls.scan(0, len(text))
+	return ls.links
+}
+
+func (ls *linkScan) template() string {
+	if len(ls.templates) == 0 {
+		return ""
+	}
+	return ls.templates[len(ls.templates)-1]
+}
+
+// templateEdge gets the EdgeType of links inside a template, or 0 if the
+// template doesn't decide it.
+func (lp *LinkParser) templateEdge(name string) EdgeType {
+	if e, ok := lp.EdgeTemplates[name]; ok {
+		return e
+	}
+	switch {
+	case strings.HasPrefix(name, "infobox"):
+		return EdgeInfobox
+	case strings.HasPrefix(name, "navbox") || strings.HasSuffix(name, "sidebar"):
+		return EdgeNavbox
+	}
+	return 0
+}
+
+// edge gets the EdgeType of a link at the scan's position.
+func (ls *linkScan) edge() EdgeType {
+	for i := len(ls.templates) - 1; i >= 0; i-- {
+		if e := ls.lp.templateEdge(ls.templates[i]); e != 0 {
+			return e
+		}
+	}
+	switch {
+	case ls.seeAlso:
+		return EdgeSeeAlso
+	case ls.tables > 0:
+		return EdgeTable
+	case ls.list:
+		return EdgeList
+	}
+	return EdgeProse
+}
+
+// flags gets the LinkFlags of a link at the scan's position.
+func (ls *linkScan) flags() LinkFlag {
+	var f LinkFlag
+	if ls.parens > 0 {
+		f |= LinkParens
+	}
+	if ls.italic {
+		f |= LinkItalic
+	}
+	if len(ls.templates) > 0 {
+		f |= LinkTemplate
+	}
+	return f
+}
+
+// line updates the scan for the line starting at text[i]: headings start
+// sections, "{|" and "|}" open and close tables, and "*", "#" and ";"
+// start list items.
+func (ls *linkScan) line(i int) {
+	end := strings.IndexByte(ls.text[i:], '\n')
+	if end < 0 {
+		end = len(ls.text) - i
+	}
+	line := strings.TrimSpace(ls.text[i : i+end])
+
+	switch {
+	case len(line) > 2 && line[0] == '=' && line[len(line)-1] == '=':
+		heading := strings.TrimSpace(strings.Trim(line, "="))
+		ls.section = ls.lp.plainText(heading)
+		ls.seeAlso = strings.ToLower(heading) == "see also"
+		ls.tables = 0
+	case strings.HasPrefix(line, "{|"):
+		ls.tables++
+	case strings.HasPrefix(line, "|}") && ls.tables > 0:
+		ls.tables--
+	}
+	ls.list = len(line) > 0 && (line[0] == '*' || line[0] == '#' || line[0] == ';')
+	ls.parens = 0
+	ls.italic = false
+}
+
+// scan finds the links in text[start:end].
+func (ls *linkScan) scan(start int, end int) {
+	text := ls.text[:end]
+	for i := start; i < end; {
+		next := strings.IndexAny(text[i:], "<[{}\n()'")
+		if next < 0 {
+			return
+		}
+		i += next
+
+		switch {
+		case text[i] == '(' || text[i] == ')' || text[i] == '\'':
+			i = ls.prose(i, end)
+
+		case text[i] == '\n':
+			i++
+			ls.line(i)
+
+		case strings.HasPrefix(text[i:], "<!--"):
+			close := strings.Index(text[i+4:], "-->")
+			if close < 0 {
+				return
+			}
+			i += 4 + close + 3
+
+		case text[i] == '<':
+			i = ls.skipTag(i, end)
+
+		case strings.HasPrefix(text[i:], "[["):
+			i = ls.link(i, end)
+
+		case strings.HasPrefix(text[i:], "{{{"):
+			// A template parameter, only in transcluded pages.
+			ls.templates = append(ls.templates, ls.template())
+			i += 3
+
+		case strings.HasPrefix(text[i:], "}}}") && len(ls.templates) > 0:
+			ls.templates = ls.templates[:len(ls.templates)-1]
+			i += 3
+
+		case strings.HasPrefix(text[i:], "{{"):
+			name := templateName(text[i+2:])
+			if ls.lp.LinkTemplates[name] {
+				ls.templateLinks(i, end, name)
+			}
+			ls.templates = append(ls.templates, name)
+			i += 2
+
+		case strings.HasPrefix(text[i:], "}}") && len(ls.templates) > 0:
+			ls.templates = ls.templates[:len(ls.templates)-1]
+			i += 2
+
+		default:
+			i++
+		}
+	}
+}
+
+// prose tracks the parentheses and italics around text[i], which is a
+// parenthesis or an apostrophe, and returns where to carry on scanning.
+// Only the article's own text counts, not the parameters of templates.
+func (ls *linkScan) prose(i int, end int) int {
+	if c := ls.text[i]; c != '\'' {
+		if len(ls.templates) == 0 {
+			if c == '(' {
+				ls.parens++
+			} else if ls.parens > 0 {
+				ls.parens--
+			}
+		}
+		return i + 1
+	}
+
+	// Runs of apostrophes: '' is italic, ''' is bold, and ''''' is both.
+	n := 1
+	for i+n < end && ls.text[i+n] == '\'' {
+		n++
+	}
+	if len(ls.templates) == 0 && (n == 2 || n >= 5) {
+		ls.italic = !ls.italic
+	}
+	return i + n
+}
+
+// skipTag skips over a tag whose contents have no links, or just past the
+// '<' of anything else.
+func (ls *linkScan) skipTag(i int, end int) int {
+	text := ls.text[:end]
+	nameEnd := i + 1
+	for nameEnd < end && isASCIILetter(text[nameEnd]) {
+		nameEnd++
+	}
+	name := strings.ToLower(text[i+1 : nameEnd])
+	if !ls.lp.SkipTags[name] {
+		return i + 1
+	}
+
+	open := strings.IndexByte(text[nameEnd:], '>')
+	if open < 0 {
+		return i + 1
+	}
+	contentStart := nameEnd + open + 1
+	if text[contentStart-2] == '/' {
+		return contentStart // <ref name="x" />
+	}
+
+	// Find the closing tag, in any case.
+	for j := contentStart; j < end; {
+		close := strings.Index(text[j:], "</")
+		if close < 0 {
+			break
+		}
+		j += close + 2
+		if j+len(name) <= end && strings.EqualFold(text[j:j+len(name)], name) {
+			if gt := strings.IndexByte(text[j:], '>'); gt >= 0 {
+				return j + gt + 1
+			}
+		}
+	}
+
+	// Unclosed, so it's shown as text.
+	return contentStart
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// linkEnd finds the end of the link starting at text[i], matching up
+// brackets so `[[A|[b]]]` ends at the last ']'. It returns the index just
+// after the link, or -1 if it isn't closed.
+func linkEnd(text string, i int) int {
+	depth := 0
+	for j := i; j < len(text); j++ {
+		switch text[j] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j + 1
+			} else if depth == 1 && j+1 < len(text) && text[j+1] != ']' {
+				// A lone ']' inside the link's text: "[[A|x] y]]".
+				depth++
+			}
+		case '\n':
+			// Only file captions run over lines.
+			if strings.IndexByte(text[i:j], '|') < 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// link parses the link at text[i], returning where to carry on scanning.
+func (ls *linkScan) link(i int, end int) int {
+	text := ls.text[:end]
+	close := linkEnd(text, i)
+	if close < 0 {
+		return i + 2
+	}
+	inner := text[i+2 : close-2]
+
+	target, anchor, piped := inner, "", false
+	if pipe := strings.IndexByte(inner, '|'); pipe >= 0 {
+		target, anchor, piped = inner[:pipe], inner[pipe+1:], true
+	}
+
+	// A link inside a link isn't one; only the inner one is.
+	if strings.Contains(target, "[[") {
+		return i + 2
+	}
+
+	l, ok := ls.lp.newLink(target)
+	if !ok {
+		return i + 2
+	}
+	l.Offset = i
+	l.Template = ls.template()
+	l.Edge = ls.edge()
+	l.Flags = ls.flags()
+	l.Context = ls.context(i)
+	l.Section = ls.section
+
+	// Files can have links in their captions, but other links can't have
+	// links in their text.
+	nested := strings.Contains(anchor, "[[")
+	if nested && l.Kind != LinkFile {
+		return i + 2
+	}
+
+	switch {
+	case l.Kind == LinkCategory:
+		// The text after the pipe is a sort key, which isn't shown.
+	case l.Kind == LinkFile:
+		// The caption is the last parameter; the others are options.
+		if piped {
+			params := splitLinkParams(anchor)
+			if caption := strings.TrimSpace(params[len(params)-1]); !isFileOption(caption) {
+				l.Anchor = caption
+			}
+		}
+	case piped && strings.TrimSpace(anchor) == "":
+		// The pipe trick: [[Queen (band)|]] shows as "Queen".
+		l.Anchor = pipeTrick(l.Target)
+	case piped:
+		l.Anchor = html.UnescapeString(strings.TrimSpace(anchor))
+	default:
+		l.Anchor = html.UnescapeString(strings.TrimSpace(strings.TrimPrefix(target, ":")))
+	}
+
+	if nested {
+		ls.links = append(ls.links, l)
+		ls.scan(i+2+len(target)+1, close-2)
+		return close
+	}
+
+	// Letters straight after a link are part of its text: [[apple]]s.
+	trail := close
+	if l.Kind == LinkArticle || l.Kind == LinkNamespace {
+		for trail < end && text[trail] >= 'a' && text[trail] <= 'z' {
+			trail++
+		}
+		l.Anchor += text[close:trail]
+	}
+
+	ls.links = append(ls.links, l)
+	return trail
+}
+
+// splitLinkParams splits the text after a link's first pipe into its
+// parameters, ignoring pipes in links inside it.
+func splitLinkParams(text string) []string {
+	var params []string
+	depth, start := 0, 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '|':
+			if depth == 0 {
+				params = append(params, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(params, text[start:])
+}
+
+// fileOptions are the keywords a file link can have instead of a caption.
+var fileOptions = map[string]bool{
+	"thumb": true, "thumbnail": true, "frame": true, "framed": true, "frameless": true,
+	"border": true, "left": true, "right": true, "center": true, "centre": true, "none": true,
+	"upright": true, "baseline": true, "middle": true, "sub": true, "super": true,
+	"top": true, "text-top": true, "bottom": true, "text-bottom": true,
+}
+
+// isFileOption returns true if a parameter of a file link is an option, like
+// "thumb", "220px" or "alt=Text", rather than a caption.
+func isFileOption(param string) bool {
+	if fileOptions[param] || strings.HasSuffix(param, "px") && strings.Trim(param[:len(param)-2], "0123456789x") == "" {
+		return true
+	}
+	eq := strings.IndexByte(param, '=')
+	return eq > 0 && isASCIILetter(param[0]) && !strings.ContainsAny(param[:eq], " [")
+}
+
+// pipeTrick gets the text MediaWiki shows for a link with an empty anchor.
+func pipeTrick(target string) string {
+	if open := strings.LastIndex(target, " ("); open > 0 && strings.HasSuffix(target, ")") {
+		return target[:open]
+	}
+	if comma := strings.Index(target, ", "); comma > 0 {
+		return target[:comma]
+	}
+	return target
+}
+
+// templateName gets the normalized name of a template from the text just
+// after its "{{".
+func templateName(text string) string {
+	end := strings.IndexAny(text, "|}{[\n")
+	if end < 0 {
+		end = len(text)
+	}
+	name := strings.ToLower(strings.Replace(strings.TrimSpace(text[:end]), "_", " ", -1))
+	name = strings.TrimPrefix(name, "template:")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// templateArgs splits the template at text[i] into its name and parameters,
+// returning nil if it isn't closed.
+func templateArgs(text string, i int) []string {
+	var args []string
+	depth, start := 0, i+2
+	for j := i; j < len(text)-1; j++ {
+		switch {
+		case text[j] == '{' && text[j+1] == '{', text[j] == '[' && text[j+1] == '[':
+			depth++
+			j++
+		case text[j] == '}' && text[j+1] == '}', text[j] == ']' && text[j+1] == ']':
+			depth--
+			if depth == 0 {
+				return append(args, text[start:j])
+			}
+			j++
+		case text[j] == '|' && depth == 1:
+			args = append(args, text[start:j])
+			start = j + 1
+		}
+	}
+	return nil
+}
+
+// templateLinks adds the titles given to a template like {{Main}} as links.
+func (ls *linkScan) templateLinks(i int, end int, name string) {
+	args := templateArgs(ls.text[:end], i)
+	if len(args) < 2 {
+		return
+	}
+	flags := ls.flags() | LinkTemplate
+	edge := ls.lp.templateEdge(name)
+	if edge == 0 {
+		edge = ls.edge()
+	}
+	for _, arg := range args[1:] {
+		if strings.Contains(arg, "=") || strings.Contains(arg, "[[") || strings.Contains(arg, "{{") {
+			continue // a named parameter, or something to render first
+		}
+		if l, ok := ls.lp.newLink(arg); ok {
+			l.Offset = i
+			l.Template = name
+			l.Edge = edge
+			l.Flags = flags
+			l.Context = ls.context(i)
+			l.Section = ls.section
+			ls.links = append(ls.links, l)
+		}
+	}
+}
+
+// newLink parses the target of a link, like "Category:Foo" or "Queen
+// (band)#Members", into a Link. It returns false if the target isn't a
+// valid title.
+func (lp *LinkParser) newLink(target string) (Link, bool) {
+	target = normalizeLinkText(html.UnescapeString(target))
+	if strings.ContainsAny(target, "<>[]{}|") || target == "" {
+		return Link{}, false
+	}
+
+	// A leading ':' makes category and file links plain links.
+	escaped := target[0] == ':'
+	if escaped {
+		target = strings.TrimSpace(target[1:])
+	}
+
+	var l Link
+	if hash := strings.IndexByte(target, '#'); hash >= 0 {
+		target, l.Fragment = strings.TrimSpace(target[:hash]), strings.TrimSpace(target[hash+1:])
+	}
+
+	if colon := strings.IndexByte(target, ':'); colon > 0 {
+		prefix := strings.TrimSpace(target[:colon])
+		rest := strings.TrimSpace(target[colon+1:])
+		key := strings.ToLower(prefix)
+
+		if ns, ok := lp.Namespaces[key]; ok {
+			l.Prefix, l.Namespace, l.Target = capitalize(prefix), ns, capitalize(rest)
+			if name, ok := lp.NamespaceNames[ns]; ok {
+				l.Prefix = name
+			}
+			switch {
+			case escaped:
+				l.Kind = LinkNamespace
+			case ns == NamespaceCategory:
+				l.Kind = LinkCategory
+			case ns == NamespaceFile || ns == NamespaceMedia:
+				l.Kind = LinkFile
+			default:
+				l.Kind = LinkNamespace
+			}
+			return l, l.Target != ""
+		}
+
+		if lp.Interwiki[key] {
+			l.Kind, l.Prefix, l.Target = LinkInterwiki, key, rest
+			return l, true
+		}
+	}
+
+	// A link to just a section of the same page has no target.
+	l.Kind, l.Target = LinkArticle, capitalize(target)
+	return l, l.Target != "" || l.Fragment != ""
+}
+
+// normalizeLinkText turns underscores into spaces, and collapses runs of
+// whitespace, as MediaWiki does for titles.
+func normalizeLinkText(s string) string {
+	return strings.Join(strings.Fields(strings.Replace(s, "_", " ", -1)), " ")
+}
+
+// capitalize uppercases the first letter of a title, as MediaWiki does.
+func capitalize(title string) string {
+	r, size := utf8.DecodeRuneInString(title)
+	if r == utf8.RuneError || unicode.IsUpper(r) {
+		return title
+	}
+	return string(unicode.ToUpper(r)) + title[size:]
+}
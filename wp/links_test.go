@@ -0,0 +1,124 @@
+package wikipath
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestParseLinksGolden parses each testdata/links/*.wiki, and compares the
+// links to the *.golden file next to it. Run with -update to rewrite them.
+func TestParseLinksGolden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/links/*.wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no test wikitext in testdata/links")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			text := readTestFile(t, path)
+			got, err := json.MarshalIndent(ParseLinks(string(text)), "", "\t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(path, ".wiki") + ".golden"
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if want := readTestFile(t, golden); string(got) != string(want) {
+				t.Errorf("links differ from %s; got\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestSetLinks(t *testing.T) {
+	links := ParseLinks("[[apple]]s, [[Category:Fruit]], [[#Top]], [[fr:Pomme]], [[:pear]] and ''[[apple]]'' [[category:fruit|Apple]]")
+	assertEqual(t, links[0].Anchor, "apples")
+
+	var sa StrippedArticle
+	sa.SetLinks(links, nil)
+	assertEqual(t, reflect.DeepEqual(sa.Links, []string{"Apple", "Pear", "Apple"}), true)
+	assertEqual(t, sa.Edges == nil, true)
+	assertEqual(t, reflect.DeepEqual(sa.Flags, []LinkFlag{0, 0, LinkItalic}), true)
+	assertEqual(t, reflect.DeepEqual(sa.Categories, []string{"Category:Fruit"}), true)
+	assertEqual(t, reflect.DeepEqual(sa.Interwiki, []InterwikiLink{{Prefix: "fr", Target: "Pomme"}}), true)
+}
+
+func TestSetLinksInterwiki(t *testing.T) {
+	links := ParseLinks("[[fr:Paris]] [[wikt:paris|Paris]] [[FR:Paris]] [[de:Paris#Geschichte]] [[:fr:Lyon]]")
+
+	var sa StrippedArticle
+	sa.SetLinks(links, nil)
+	assertEqual(t, len(sa.Links), 0)
+	assertEqual(t, reflect.DeepEqual(sa.Interwiki, []InterwikiLink{
+		{Prefix: "fr", Target: "Paris"},
+		{Prefix: "wikt", Target: "paris"}, // Wiktionary titles keep their case.
+		{Prefix: "de", Target: "Paris"},
+		{Prefix: "fr", Target: "Lyon"},
+	}), true)
+	assertEqual(t, sa.Interwiki[0].String(), "fr:Paris")
+
+	sa.Title = "Paris"
+	index := NewIndex()
+	index.AddArticle(&sa)
+	index.Build()
+	assertEqual(t, reflect.DeepEqual(index.Get("Paris").Interwiki, sa.Interwiki), true)
+}
+
+func TestLinkParserNamespaces(t *testing.T) {
+	lp := NewLinkParser()
+	lp.Namespaces["kategorie"] = NamespaceCategory
+	delete(lp.Interwiki, "fr")
+
+	links := lp.Parse("[[Kategorie:Rockband]] [[fr:Paris]]")
+	assertEqual(t, len(links), 2)
+	assertEqual(t, links[0].Kind, LinkCategory)
+	assertEqual(t, links[0].Title(), "Category:Rockband") // Aliases get the namespace's name.
+	assertEqual(t, links[1].Kind, LinkArticle)
+	assertEqual(t, links[1].Target, "Fr:Paris")
+}
+
+func FuzzParseLinks(f *testing.F) {
+	paths, _ := filepath.Glob("testdata/links/*.wiki")
+	for _, path := range paths {
+		if text, err := ioutil.ReadFile(path); err == nil {
+			f.Add(string(text))
+		}
+	}
+	f.Add("[[a|[[b]]]] {{main|[[c]]}} <ref>[[d]]")
+
+	f.Fuzz(func(t *testing.T, text string) {
+		last := -1
+		for _, l := range ParseLinks(text) {
+			if l.Offset < 0 || l.Offset+2 > len(text) {
+				t.Fatalf("offset %d is out of range", l.Offset)
+			}
+			if !strings.HasPrefix(text[l.Offset:], "[[") && !strings.HasPrefix(text[l.Offset:], "{{") {
+				t.Fatalf("link %+v doesn't start at its offset", l)
+			}
+			if l.Offset < last && l.Template == "" {
+				t.Fatalf("link %+v is out of order", l)
+			}
+			last = l.Offset
+			if strings.ContainsAny(l.Target, "<>[]{}|") {
+				t.Fatalf("target %q has characters titles can't", l.Target)
+			}
+			if utf8.ValidString(text) && !utf8.ValidString(l.Target) {
+				t.Fatalf("target %q isn't valid UTF-8", l.Target)
+			}
+		}
+	})
+}
@@ -6,7 +6,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"io"
-	"regexp"
+	"math"
 	"runtime"
 	"strconv"
 	"strings"
@@ -38,68 +38,202 @@ type Article struct {
 const chanSize int = 1024       // Buffers inbetween all channels
 const readerBufSize int = 50000 // File buffers in front of OS
 
+// Chunk is a single bzip2 stream of a multistream wiki archive, as a range
+// of byte offsets. The last chunk of an archive has End = -1, and runs to
+// the end of the file.
+type Chunk struct {
+	Start int64
+	End   int64
+}
+
+// ChunkOptions configures how LoadWikiChunks reads an archive.
+type ChunkOptions struct {
+	// Skip, if set, is called for each chunk before it is read. Chunks
+	// for which it returns true are not loaded.
+	Skip func(Chunk) bool
+
+	// Ordered makes the visitor see chunks in archive order, rather than
+	// the order they finish decompressing in. At most Window chunks are
+	// held back waiting for an earlier one.
+	Ordered bool
+	Window  int // Defaults to 4 chunks per worker.
+}
+
+// sequencedChunk is a chunk and its position among the chunks being loaded.
+type sequencedChunk struct {
+	Chunk
+	seq int
+}
+
+// chunkResult is the articles decompressed from one chunk.
+type chunkResult struct {
+	chunk    sequencedChunk
+	articles []*Article
+}
+
 // LoadWikiCompressed loads a compressed wiki archive, calling `visitor` for each article it reads.
 func LoadWikiCompressed(index io.Reader, source io.ReaderAt, visitor func(*Article) bool) error {
 	ec := NewErrorContext()
+	return LoadWikiChunks(ec, index, source, ChunkOptions{}, func(_ Chunk, articles []*Article) bool {
+		for _, a := range articles {
+			if !visitor(a) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// LoadWikiChunks loads a multistream wiki archive, decompressing its
+// chunks in parallel and calling `visitor` once for all the articles of
+// each chunk, stopping if it returns false. Canceling `ec` stops the load,
+// and LoadWikiChunks returns the error it was canceled with.
+func LoadWikiChunks(ec *ErrorContext, index io.Reader, source io.ReaderAt, opts ChunkOptions, visitor func(Chunk, []*Article) bool) error {
+	window := opts.window()
+	chunks := loadIndexChunks(ec, index, opts.Skip, window)
+	return loadChunks(ec, chunks, window, source, opts, visitor)
+}
 
-	chunks := loadIndexChunks(ec, index)
-	articles := make(chan *Article, chanSize)
+// LoadWikiStreams is LoadWikiChunks for a multistream archive without an
+// index, given the offsets of its bzip2 streams from FindStreams. Each
+// stream must hold whole pages, as the ones in Wikimedia's dumps do.
+func LoadWikiStreams(ec *ErrorContext, streams []int64, source io.ReaderAt, opts ChunkOptions, visitor func(Chunk, []*Article) bool) error {
+	window := opts.window()
+	chunks := make(chan sequencedChunk, chanSize)
+	send := chunkSender(ec, chunks, opts.Skip, window)
 
+	go func() {
+		defer close(chunks)
+		for i, start := range streams {
+			end := int64(-1)
+			if i+1 < len(streams) {
+				end = streams[i+1]
+			}
+			if !send(Chunk{start, end}) {
+				return
+			}
+		}
+	}()
+
+	return loadChunks(ec, chunks, window, source, opts, visitor)
+}
+
+// window makes the channel which limits how far ahead of the visitor
+// chunks can be decompressed in ordered mode, or returns nil.
+func (opts ChunkOptions) window() chan struct{} {
+	if !opts.Ordered {
+		return nil
+	}
+
+	// Every chunk takes a slot in the window until it's been visited,
+	// which bounds how many finished chunks can pile up behind a slow one.
+	size := opts.Window
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(-1) * 4
+	}
+	return make(chan struct{}, size)
+}
+
+// loadChunks decompresses `chunks` in parallel, and visits the articles in
+// each one.
+func loadChunks(ec *ErrorContext, chunks <-chan sequencedChunk, window chan struct{}, source io.ReaderAt, opts ChunkOptions, visitor func(Chunk, []*Article) bool) error {
 	nWorkers := runtime.GOMAXPROCS(-1)
+	results := make(chan chunkResult, chanSize)
 
+	var workers sync.WaitGroup
 	for i := 0; i < nWorkers; i++ {
-		decompressChunks(ec, source, chunks, articles)
+		workers.Add(1)
+		go func() {
+			decompressChunks(ec, source, chunks, results)
+			workers.Done()
+		}()
 	}
 
-	var done sync.WaitGroup
 	go func() {
-		done.Add(1)
-		for a := range articles {
-			shouldCont := visitor(a)
+		workers.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]chunkResult) // Finished chunks, waiting their turn.
+	next := 0                            // Next chunk to visit, in ordered mode.
 
-			if !shouldCont {
+	for res := range results {
+		select {
+		case <-ec.Canceled:
+			continue // drain results until the workers stop
+		default:
+		}
+
+		if !opts.Ordered {
+			if !visitor(res.chunk.Chunk, res.articles) {
 				ec.Cancel(ErrStopped)
 			}
+			continue
 		}
-		done.Done()
-	}()
 
-	ec.Wait()
-	close(articles)
-	done.Wait()
-	return ec.Err
+		pending[res.chunk.seq] = res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-window
+
+			if !visitor(res.chunk.Chunk, res.articles) {
+				ec.Cancel(ErrStopped)
+				break
+			}
+		}
+	}
+
+	select {
+	case <-ec.Canceled:
+		return ec.Err
+	default:
+		return nil
+	}
 }
 
-func decompressChunks(ec *ErrorContext, archiveFile io.ReaderAt, chunks <-chan [2]int64, articles chan<- *Article) {
-	ec.Start()
-	go func() {
-		for chunk := range chunks {
-			chunkRaw := io.NewSectionReader(archiveFile, chunk[0], chunk[1]-chunk[0])
-			chunkReader := bufio.NewReaderSize(chunkRaw, readerBufSize)
-			chunkDecompressor := bzip2.NewReader(chunkReader)
-			loadErr := LoadWiki(chunkDecompressor, func(a *Article) bool {
-				select {
-				case <-ec.Canceled:
-					// Load has been canceled
-					return false
-				default:
-					articles <- a
-					// Still loading
-					return true
-				}
-			})
+// decompressChunks reads chunks from `chunks` until it is closed or `ec`
+// is canceled, sending the articles in each to `results`.
+func decompressChunks(ec *ErrorContext, archiveFile io.ReaderAt, chunks <-chan sequencedChunk, results chan<- chunkResult) {
+	for chunk := range chunks {
+		size := chunk.End - chunk.Start
+		if chunk.End < 0 {
+			size = math.MaxInt64 - chunk.Start
+		}
+		chunkRaw := io.NewSectionReader(archiveFile, chunk.Start, size)
+		chunkReader := bufio.NewReaderSize(chunkRaw, readerBufSize)
+		chunkDecompressor := bzip2.NewReader(chunkReader)
+
+		res := chunkResult{chunk: chunk}
+		loadErr := LoadWiki(chunkDecompressor, func(a *Article) bool {
+			res.articles = append(res.articles, a)
+			return true
+		})
+
+		if loadErr != nil {
+			ec.Cancel(loadErr)
+			return
+		}
 
-			if loadErr != nil {
-				ec.Cancel(loadErr)
-				return
-			}
+		select {
+		case results <- res:
+		case <-ec.Canceled:
+			return
 		}
-		ec.Done()
-	}()
+	}
 }
 
-func loadIndexChunks(ec *ErrorContext, indexRaw io.Reader) <-chan [2]int64 {
-	chunks := make(chan [2]int64, chanSize)
+// loadIndexChunks reads the chunk offsets from a multistream index, and
+// sends each chunk not skipped by `skip` to the returned channel, numbered
+// in archive order. If `window` is set, each chunk waits for a slot in it
+// before being sent.
+func loadIndexChunks(ec *ErrorContext, indexRaw io.Reader, skip func(Chunk) bool, window chan struct{}) <-chan sequencedChunk {
+	chunks := make(chan sequencedChunk, chanSize)
+	send := chunkSender(ec, chunks, skip, window)
 
 	// Open a decompressing reader on indexPath
 	indexBuf := bufio.NewReaderSize(indexRaw, readerBufSize)
@@ -107,6 +241,8 @@ func loadIndexChunks(ec *ErrorContext, indexRaw io.Reader) <-chan [2]int64 {
 	indexScanner := bufio.NewScanner(indexReader)
 
 	go func() {
+		defer close(chunks)
+
 		// Load the first line, get the first offset
 		var offset int64 // = 0
 
@@ -118,23 +254,53 @@ func loadIndexChunks(ec *ErrorContext, indexRaw io.Reader) <-chan [2]int64 {
 				return
 			}
 			if chunk > offset {
-				chunks <- [2]int64{offset, chunk}
+				if !send(Chunk{offset, chunk}) {
+					return
+				}
 				offset = chunk
 			}
 		}
 
-		close(chunks)
-
 		err := indexScanner.Err()
 		if err != nil {
 			ec.Cancel(err)
 			return
 		}
+
+		// The last stream runs to the end of the archive.
+		send(Chunk{offset, -1})
 	}()
 
 	return chunks
 }
 
+// chunkSender returns a function which sends each chunk not skipped by
+// `skip` to `chunks`, numbered in archive order. If `window` is set, each
+// chunk waits for a slot in it before being sent. It returns false if `ec`
+// is canceled.
+func chunkSender(ec *ErrorContext, chunks chan<- sequencedChunk, skip func(Chunk) bool, window chan struct{}) func(Chunk) bool {
+	seq := 0
+	return func(chunk Chunk) bool {
+		if skip != nil && skip(chunk) {
+			return true
+		}
+		if window != nil {
+			select {
+			case window <- struct{}{}:
+			case <-ec.Canceled:
+				return false
+			}
+		}
+		select {
+		case chunks <- sequencedChunk{chunk, seq}:
+			seq++
+			return true
+		case <-ec.Canceled:
+			return false
+		}
+	}
+}
+
 // LoadWiki loads articles from an `io.Reader` over wiki archive XML,
 // calling `visitor` for each one and stopping if it returns false.
 func LoadWiki(source io.Reader, visitor func(*Article) bool) error {
@@ -169,22 +335,6 @@ func LoadWiki(source io.Reader, visitor func(*Article) bool) error {
 	}
 }
 
-// linkRegex extracts links from wikitext.
-// https://regex101.com/r/Q2bNwC/3
-var linkRegex = regexp.MustCompile(`(?U)\[\[([^]:]+)([#/|].+)?\]\]`)
-
-// ParseLinks Returns a list of strings, representing the titles of articles.
-func ParseLinks(text string) []string {
-	matches := linkRegex.FindAllStringSubmatchIndex(text, -1)
-	linkNames := make([]string, len(matches))
-	for i, inds := range matches {
-		start := inds[2]
-		end := inds[3]
-		linkNames[i] = text[start:end]
-	}
-	return linkNames
-}
-
 // ParseIndexLine parses a line of the wiki multistream index,
 // returning (byte offset, article id, article title) tuples.
 func ParseIndexLine(line string) (int64, uint, string, error) {
@@ -72,14 +72,81 @@ const testWikitext = `
 
 func TestParseLinks(t *testing.T) {
 	links := ParseLinks(testWikitext)
-	assertEqual(t, links[0], "Sandbox")
-	assertEqual(t, links[1], "Fox Broadcasting Company")
-	assertEqual(t, links[2], "Queen (band)")
-	assertEqual(t, links[3], "Queen (chess)")
-	assertEqual(t, links[4], "Target page")
-	assertEqual(t, links[5], "War and Peace")
-	assertEqual(t, links[6], "Cilk")
-	assertEqual(t, links[7], "C (programming language)")
+	assertEqual(t, len(links), 10)
+	assertEqual(t, links[0].Target, "Sandbox")
+	assertEqual(t, links[1].Target, "Fox Broadcasting Company")
+	assertEqual(t, links[1].Anchor, "Fox")
+	assertEqual(t, links[2].Target, "Queen (band)")
+	assertEqual(t, links[3].Target, "Queen (chess)")
+	assertEqual(t, links[4].Target, "Target page")
+	assertEqual(t, links[4].Fragment, "Target section")
+	assertEqual(t, links[5].Kind, LinkNamespace)
+	assertEqual(t, links[5].Title(), "Wikipedia:Tutorial/Wikipedia links")
+	assertEqual(t, links[6].Target, "War and Peace")
+	assertEqual(t, links[7].Kind, LinkFile)
+	assertEqual(t, links[7].Anchor, "Addition")
+	assertEqual(t, links[8].Target, "Cilk")
+	assertEqual(t, links[9].Target, "C (programming language)")
+
+	for _, l := range links {
+		assertEqual(t, testWikitext[l.Offset:l.Offset+2], "[[")
+	}
+}
+
+func loadTestChunks(t *testing.T, opts ChunkOptions) ([]Chunk, []*Article) {
+	index, indexErr := os.Open("testdata/multistream-index.txt.bz2")
+	archive, archiveErr := os.Open("testdata/multistream.xml.bz2")
+	if indexErr != nil || archiveErr != nil {
+		t.Fatal(indexErr, archiveErr)
+	}
+	defer index.Close()
+	defer archive.Close()
+
+	var chunks []Chunk
+	var articles []*Article
+	err := LoadWikiChunks(NewErrorContext(), index, archive, opts, func(c Chunk, as []*Article) bool {
+		chunks = append(chunks, c)
+		articles = append(articles, as...)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chunks, articles
+}
+
+func TestLoadWikiChunks(t *testing.T) {
+	t.Run("All", func(t *testing.T) {
+		chunks, articles := loadTestChunks(t, ChunkOptions{})
+		assertEqual(t, len(chunks), 41) // the header, then one per stream of pages
+		assertEqual(t, len(articles), 200)
+	})
+
+	t.Run("Ordered", func(t *testing.T) {
+		for _, window := range []int{1, 3, 0} {
+			chunks, articles := loadTestChunks(t, ChunkOptions{Ordered: true, Window: window})
+			for i := 1; i < len(chunks); i++ {
+				if chunks[i].Start <= chunks[i-1].Start {
+					t.Fatalf("window %d: chunk %v came after %v", window, chunks[i], chunks[i-1])
+				}
+			}
+			for i, a := range articles {
+				assertEqual(t, a.ID, i+1)
+			}
+			assertEqual(t, chunks[len(chunks)-1].End, int64(-1))
+		}
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		all, _ := loadTestChunks(t, ChunkOptions{Ordered: true})
+		skipped := map[int64]bool{all[1].Start: true, all[5].Start: true}
+		chunks, articles := loadTestChunks(t, ChunkOptions{
+			Ordered: true,
+			Skip:    func(c Chunk) bool { return skipped[c.Start] },
+		})
+		assertEqual(t, len(chunks), len(all)-2)
+		assertEqual(t, len(articles), 190)
+	})
 }
 
 func checkError(b *testing.B, err error) {
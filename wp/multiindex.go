@@ -0,0 +1,171 @@
+package wikipath
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MultiIndex joins the indexes of several languages' wikis into one graph,
+// with sitelinks from Wikidata between pages about the same thing. Paths
+// can then switch language at any page with a sitelink.
+type MultiIndex struct {
+	indexes   map[string]*Index // Indexes by language code.
+	languages []string          // Language codes, in the order added.
+}
+
+// NewMultiIndex creates a MultiIndex.
+func NewMultiIndex() *MultiIndex {
+	return &MultiIndex{indexes: make(map[string]*Index)}
+}
+
+// Add adds the index of the wiki in language `lang`, like "de". The first
+// index added is the default, for titles with no language.
+func (mi *MultiIndex) Add(lang string, ind *Index) {
+	if mi.indexes[lang] == nil {
+		mi.languages = append(mi.languages, lang)
+	}
+	ind.SetLanguage(lang)
+	mi.indexes[lang] = ind
+}
+
+// Languages gets the language codes of each index, in the order added.
+func (mi *MultiIndex) Languages() []string {
+	return mi.languages
+}
+
+// Index gets the index of a language, or nil if it hasn't got one.
+func (mi *MultiIndex) Index(lang string) *Index {
+	return mi.indexes[lang]
+}
+
+// Sites gets the Wikidata site IDs of each index, like "dewiki", for
+// reading only their sitelinks.
+func (mi *MultiIndex) Sites() map[string]bool {
+	sites := make(map[string]bool)
+	for _, lang := range mi.languages {
+		sites[WikiSite(lang)] = true
+	}
+	return sites
+}
+
+// split splits the language off a title like "de:Berlin", if there's an
+// index for it. Titles without one are in the default language.
+func (mi *MultiIndex) split(title string) (*Index, string) {
+	if colon := strings.IndexByte(title, ':'); colon > 0 {
+		if ind := mi.indexes[strings.ToLower(strings.TrimSpace(title[:colon]))]; ind != nil {
+			return ind, strings.TrimSpace(title[colon+1:])
+		}
+	}
+	if len(mi.languages) == 0 {
+		return nil, title
+	}
+	return mi.indexes[mi.languages[0]], title
+}
+
+// Get gets an IndexItem by its title, with the language before it like
+// "de:Berlin".
+func (mi *MultiIndex) Get(title string) *IndexItem {
+	ind, title := mi.split(title)
+	if ind == nil {
+		return nil
+	}
+	return ind.Get(title)
+}
+
+// GetByID gets an IndexItem by page ID, with the language before it like
+// "de:#1234", or "#1234" in the default language.
+func (mi *MultiIndex) GetByID(ref string) *IndexItem {
+	ind, ref := mi.split(ref)
+	if ind == nil || !strings.HasPrefix(ref, "#") {
+		return nil
+	}
+	id, err := strconv.Atoi(ref[1:])
+	if err != nil {
+		return nil
+	}
+	return ind.GetByID(id)
+}
+
+// Build builds each index which isn't ready.
+func (mi *MultiIndex) Build() {
+	for _, ind := range mi.indexes {
+		if !ind.ready {
+			ind.Build()
+		}
+	}
+}
+
+// AddSitelinks joins the pages of each Wikidata item in `sl` across the
+// languages of the MultiIndex. Pages which are redirects join the page
+// they redirect to. It returns how many pages were joined.
+func (mi *MultiIndex) AddSitelinks(sl Sitelinks) int {
+	// Redirects are only known once the indexes are built.
+	mi.Build()
+
+	joined := 0
+	for _, pages := range sl {
+		// Sites are taken in order, so sitelinks are the same every time.
+		sites := make([]string, 0, len(pages))
+		for site := range pages {
+			sites = append(sites, site)
+		}
+		sort.Strings(sites)
+
+		var items []*IndexItem
+		seen := make(map[*IndexItem]bool)
+		for _, site := range sites {
+			ind := mi.indexes[SiteLanguage(site)]
+			if ind == nil {
+				continue
+			}
+			if item := ind.Get(pages[site]); item != nil && !seen[item] {
+				seen[item] = true
+				items = append(items, item)
+			}
+		}
+		if len(items) < 2 {
+			continue
+		}
+
+		for _, item := range items {
+			for _, other := range items {
+				if other != item && !hasItem(item.Sitelinks, other) {
+					item.Sitelinks = append(item.Sitelinks, other)
+				}
+			}
+		}
+		joined += len(items)
+	}
+	return joined
+}
+
+// hasItem returns true if `items` has `item` in it.
+func hasItem(items []*IndexItem, item *IndexItem) bool {
+	for _, it := range items {
+		if it == item {
+			return true
+		}
+	}
+	return false
+}
+
+// FindPathWith finds a path between two IndexItems, which may be in
+// different languages, like Index.FindPathWith does.
+func (mi *MultiIndex) FindPathWith(from *IndexItem, to *IndexItem, depth int, opts PathOptions) (path *IndexPath, searched int) {
+	if from == to {
+		return NewIndexPath(from, FORWARD), 0
+	}
+	mi.Build()
+	opts = opts.withDefaults()
+	return pathSearch(from, to, depth, &opts)
+}
+
+// FullTitle gets the title of an item with its language before it, like
+// "de:Berlin", or only its title if it has no language.
+func (it *IndexItem) FullTitle() string {
+	if it.Language == "" {
+		return it.Title
+	}
+	return it.Language + ":" + it.Title
+}
@@ -0,0 +1,81 @@
+package wikipath
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sitelinksSQL = "CREATE TABLE `wb_items_per_site` (\n" +
+	"  `ips_row_id` bigint(20) NOT NULL AUTO_INCREMENT,\n" +
+	"  `ips_item_id` int(10) unsigned NOT NULL,\n" +
+	"  `ips_site_id` varbinary(32) NOT NULL,\n" +
+	"  `ips_site_page` varbinary(310) NOT NULL,\n" +
+	"  PRIMARY KEY (`ips_row_id`)\n" +
+	");\n" +
+	"INSERT INTO `wb_items_per_site` VALUES (1,183,'dewiki','Deutschland'),(2,183,'jawiki','ドイツ')," +
+	"(3,183,'enwiki','Germany'),(4,46383,'jawiki','寿司'),(5,46383,'dewiki','Sushi');\n"
+
+func TestReadSitelinks(t *testing.T) {
+	want := Sitelinks{183: {"dewiki": "Deutschland", "jawiki": "ドイツ"}, 46383: {"jawiki": "寿司", "dewiki": "Sushi"}}
+	sites := map[string]bool{"dewiki": true, "jawiki": true}
+
+	sl, err := ReadSitelinksSQL(strings.NewReader(sitelinksSQL), sites)
+	assertEqual(t, err, nil)
+	assertEqual(t, reflect.DeepEqual(sl, want), true)
+
+	sl, err = ReadSitelinksJSON(strings.NewReader(`{"Q183": {"dewiki": "Deutschland", "jawiki": "ドイツ", "enwiki": "Germany"}, "q46383": {"jawiki": "寿司", "dewiki": "Sushi"}}`), sites)
+	assertEqual(t, err, nil)
+	assertEqual(t, reflect.DeepEqual(sl, want), true)
+
+	// As the Wikidata API gives them.
+	sl, err = ReadSitelinksJSON(strings.NewReader(`{"entities": {"Q183": {"sitelinks": {"dewiki": {"site": "dewiki", "title": "Deutschland"}, "jawiki": {"title": "ドイツ"}}}}}`), nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, reflect.DeepEqual(sl, Sitelinks{183: want[183]}), true)
+
+	_, err = ReadSitelinksJSON(strings.NewReader(`{"Berlin": {}}`), nil)
+	assertEqual(t, err != nil, true)
+
+	assertEqual(t, WikiSite("zh-yue"), "zh_yuewiki")
+	assertEqual(t, SiteLanguage("zh_yuewiki"), "zh-yue")
+	assertEqual(t, SiteLanguage("dewikiquote"), "")
+}
+
+func TestMultiIndex(t *testing.T) {
+	de := NewIndex()
+	for _, a := range []*Article{
+		{Title: "Berlin", ID: 1, Text: "[[Deutschland]]"},
+		{Title: "Deutschland", ID: 2, Text: "[[Berlin]]"},
+		{Title: "Sushi", ID: 3, Text: ""},
+	} {
+		de.AddArticle(NewStrippedArticle(a))
+	}
+	ja := NewIndex()
+	for _, a := range []*Article{
+		{Title: "ドイツ", ID: 1, Text: "[[日本]]"},
+		{Title: "日本", ID: 2, Text: "[[寿司]]"},
+		{Title: "寿司", ID: 3, Text: ""},
+		{Title: "すし", ID: 4, Redirect: Redirect{Title: "寿司"}},
+	} {
+		ja.AddArticle(NewStrippedArticle(a))
+	}
+
+	mi := NewMultiIndex()
+	mi.Add("de", de)
+	mi.Add("ja", ja)
+	assertEqual(t, reflect.DeepEqual(mi.Sites(), map[string]bool{"dewiki": true, "jawiki": true}), true)
+	assertEqual(t, mi.AddSitelinks(Sitelinks{183: {"dewiki": "Deutschland", "jawiki": "ドイツ"}, 46383: {"dewiki": "Sushi", "jawiki": "すし"}, 64: {"dewiki": "Berlin"}}), 4)
+
+	// Titles without a language are in the first.
+	berlin := mi.Get("Berlin")
+	assertEqual(t, berlin.FullTitle(), "de:Berlin")
+	assertEqual(t, mi.Get("ja:Berlin") == nil, true)
+	assertEqual(t, mi.GetByID("ja:#3"), mi.Get("ja:寿司"))
+	assertEqual(t, mi.Get("ja:すし").Sitelinks[0], mi.Get("de:Sushi"))
+
+	path, _ := mi.FindPathWith(berlin, mi.Get("ja:寿司"), 10, PathOptions{})
+	assertEqual(t, path.String(), "de:Berlin > de:Deutschland = ja:ドイツ > ja:日本 > ja:寿司")
+	hops := path.Hops()
+	assertEqual(t, hops[0].Switch, false)
+	assertEqual(t, hops[1].Switch, true)
+}
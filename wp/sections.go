@@ -0,0 +1,53 @@
+package wikipath
+
+// ArticleSection is a section of an article, as a node below it: the
+// articles the section links to.
+type ArticleSection struct {
+	Item    *IndexItem   // Article the section is in.
+	Heading string       // Heading of the section, "" for the lead.
+	Forward []*IndexItem // Articles the section links to.
+}
+
+// Title gets the title of the section, like "Queen (band)#History", or the
+// article's title for its lead.
+func (s *ArticleSection) Title() string {
+	return sectionTitle(s.Item.FullTitle(), s.Heading)
+}
+
+// sectionTitle puts a section's heading after a title, if it has one.
+func sectionTitle(title string, heading string) string {
+	if heading == "" {
+		return title
+	}
+	return title + "#" + heading
+}
+
+// Sections splits the articles `item` links to by the section they're in,
+// in the order the sections are first linked from. An article linked to
+// from more than one section is in the one its context came from, which is
+// its first mention in prose. Returns nil if sections aren't known.
+func (ind *Index) Sections(item *IndexItem) []*ArticleSection {
+	if !ind.ready {
+		ind.Build()
+	}
+	if len(item.ForwardContext) == 0 {
+		return nil
+	}
+
+	var sections []*ArticleSection
+	byHeading := make(map[string]*ArticleSection)
+	for i, dst := range item.Forward {
+		heading := ""
+		if i < len(item.ForwardContext) && item.ForwardContext[i] != nil {
+			heading = item.ForwardContext[i].Section
+		}
+		s := byHeading[heading]
+		if s == nil {
+			s = &ArticleSection{Item: item, Heading: heading}
+			byHeading[heading] = s
+			sections = append(sections, s)
+		}
+		s.Forward = append(s.Forward, dst)
+	}
+	return sections
+}
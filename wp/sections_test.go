@@ -0,0 +1,63 @@
+package wikipath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetLinksSections(t *testing.T) {
+	links := ParseLinks("[[A]] in the lead.\n== History ==\n[[B#Early life|B]] and [[C]].\n=== ''Later'' years ===\n[[D]]")
+
+	var sa StrippedArticle
+	sa.SetLinks(links, nil)
+	assertEqual(t, reflect.DeepEqual(sa.Links, []string{"A", "B", "C", "D"}), true)
+	assertEqual(t, reflect.DeepEqual(sa.Sections, []string{"", "History", "History", "Later years"}), true)
+	assertEqual(t, reflect.DeepEqual(sa.Fragments, []string{"", "Early life", "", ""}), true)
+
+	// Neither is kept if no link has one.
+	sa.SetLinks(ParseLinks("[[A]] [[B]]"), nil)
+	assertEqual(t, sa.Sections == nil && sa.Fragments == nil, true)
+}
+
+func sectionsTestIndex() *Index {
+	ind := NewIndex()
+	for _, a := range []*Article{
+		{Title: "A", Text: "[[B#Members|B]]"},
+		{Title: "B", Text: "A band.\n== Members ==\n[[D]]\n== History ==\nThey played at [[C]]. [[D]]"},
+		{Title: "C", Text: ""},
+		{Title: "D", Text: "[[A#Top]]"},
+	} {
+		ind.AddArticle(NewStrippedArticle(a))
+	}
+	ind.Build()
+	return ind
+}
+
+func TestPathSections(t *testing.T) {
+	ind := sectionsTestIndex()
+
+	// B is shown with the section it links to C from.
+	path, _ := ind.FindPath(ind.Get("A"), ind.Get("C"), 10)
+	assertEqual(t, path.String(), "A > B#History > C")
+	hops := path.Hops()
+	assertEqual(t, hops[0].Context.Fragment, "Members")
+	assertEqual(t, hops[1].Context.Section, "History")
+	assertEqual(t, hops[1].Context.Sentence, "They played at C.")
+
+	// Or else the section the last article linked to.
+	path, _ = ind.FindPath(ind.Get("D"), ind.Get("A"), 10)
+	assertEqual(t, path.String(), "D > A#Top")
+}
+
+func TestIndexSections(t *testing.T) {
+	ind := sectionsTestIndex()
+
+	sections := ind.Sections(ind.Get("B"))
+	assertEqual(t, len(sections), 2)
+	assertEqual(t, sections[0].Title(), "B#Members")
+	assertEqual(t, reflect.DeepEqual(sections[0].Forward, []*IndexItem{ind.Get("D")}), true)
+	assertEqual(t, sections[1].Title(), "B#History")
+	assertEqual(t, reflect.DeepEqual(sections[1].Forward, []*IndexItem{ind.Get("C")}), true)
+
+	assertEqual(t, ind.Sections(ind.Get("C")) == nil, true)
+}
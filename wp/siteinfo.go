@@ -0,0 +1,170 @@
+package wikipath
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoSiteInfo is returned when a wiki archive has no <siteinfo>.
+var ErrNoSiteInfo = errors.New("no <siteinfo> in wiki archive")
+
+// SiteInfo is the <siteinfo> at the start of a wiki archive, describing the
+// wiki it came from.
+type SiteInfo struct {
+	SiteName   string          `xml:"sitename"`
+	DBName     string          `xml:"dbname"`
+	Base       string          `xml:"base"`
+	Case       string          `xml:"case"`
+	Namespaces []SiteNamespace `xml:"namespaces>namespace"`
+}
+
+// SiteNamespace is a namespace of a wiki, from its <siteinfo>.
+type SiteNamespace struct {
+	Key  int    `xml:"key,attr"`
+	Case string `xml:"case,attr"`
+	Name string `xml:",chardata"`
+}
+
+// ReadSiteInfo reads the <siteinfo> from the start of a wiki archive's XML,
+// stopping once it has it.
+func ReadSiteInfo(source io.Reader) (*SiteInfo, error) {
+	decoder := xml.NewDecoder(source)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil, ErrNoSiteInfo
+		} else if err != nil {
+			return nil, err
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "siteinfo":
+			var si SiteInfo
+			if err := decoder.DecodeElement(&si, &se); err != nil {
+				return nil, err
+			}
+			return &si, nil
+		case "page":
+			return nil, ErrNoSiteInfo
+		}
+	}
+}
+
+// LinkParser creates a LinkParser for the wiki, which knows its namespaces
+// as well as the English names every wiki has.
+func (si *SiteInfo) LinkParser() *LinkParser {
+	lp := NewLinkParser()
+	for _, ns := range si.Namespaces {
+		name := strings.TrimSpace(ns.Name)
+		if ns.Key == NamespaceMain || name == "" {
+			continue
+		}
+		lp.Namespaces[strings.ToLower(name)] = ns.Key
+		lp.NamespaceNames[ns.Key] = name
+		delete(lp.Interwiki, strings.ToLower(name))
+	}
+	return lp
+}
+
+// NamespaceFilter picks which namespaces' pages to index. The nil filter
+// picks only the main namespace, where articles are.
+type NamespaceFilter struct {
+	All     bool         // Include every namespace not in Exclude.
+	Include map[int]bool // Namespaces to include.
+	Exclude map[int]bool // Namespaces to leave out, even with All.
+}
+
+// AllNamespaces is a filter which includes pages in every namespace.
+var AllNamespaces = &NamespaceFilter{All: true}
+
+// Has returns true if the filter includes namespace `ns`.
+func (nf *NamespaceFilter) Has(ns int) bool {
+	if nf == nil {
+		return ns == NamespaceMain
+	}
+	if nf.Exclude[ns] {
+		return false
+	}
+	return nf.All || nf.Include[ns]
+}
+
+// String lists the namespaces the filter includes, by number.
+func (nf *NamespaceFilter) String() string {
+	if nf == nil {
+		return "0"
+	}
+	var parts []string
+	if nf.All {
+		parts = append(parts, "all")
+	}
+	for _, set := range []struct {
+		nss    map[int]bool
+		prefix string
+	}{{nf.Include, ""}, {nf.Exclude, "-"}} {
+		var nss []int
+		for ns, ok := range set.nss {
+			if ok {
+				nss = append(nss, ns)
+			}
+		}
+		sort.Ints(nss)
+		for _, ns := range nss {
+			parts = append(parts, set.prefix+strconv.Itoa(ns))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseNamespaces parses a list of namespaces separated by commas, like
+// "main,portal" or "0,100". Names are looked up in `lp`. "all" is every
+// namespace, and a '-' before a namespace leaves it out, so "-talk,-user"
+// is every namespace but Talk and User.
+func ParseNamespaces(list string, lp *LinkParser) (*NamespaceFilter, error) {
+	nf := &NamespaceFilter{Include: make(map[int]bool), Exclude: make(map[int]bool)}
+	for i, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		remove := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		if remove && i == 0 {
+			nf.All = true
+		}
+
+		if name == "all" {
+			nf.All = !remove
+			continue
+		}
+
+		ns, err := strconv.Atoi(name)
+		if err != nil {
+			var ok bool
+			ns, ok = lp.Namespaces[name]
+			if name == "main" || name == "article" {
+				ns, ok = NamespaceMain, true
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown namespace '%s'", name)
+			}
+		}
+
+		if remove {
+			nf.Exclude[ns] = true
+			delete(nf.Include, ns)
+		} else {
+			nf.Include[ns] = true
+			delete(nf.Exclude, ns)
+		}
+	}
+	return nf, nil
+}
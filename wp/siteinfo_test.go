@@ -0,0 +1,86 @@
+package wikipath
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSiteInfo = `<mediawiki xml:lang="de">
+  <siteinfo>
+    <sitename>Wikipedia</sitename>
+    <dbname>dewiki</dbname>
+    <case>first-letter</case>
+    <namespaces>
+      <namespace key="-2" case="first-letter">Medium</namespace>
+      <namespace key="0" case="first-letter" />
+      <namespace key="6" case="first-letter">Datei</namespace>
+      <namespace key="14" case="first-letter">Kategorie</namespace>
+      <namespace key="100" case="first-letter">Portal</namespace>
+    </namespaces>
+  </siteinfo>
+  <page>
+    <title>Rom</title>
+  </page>
+</mediawiki>`
+
+func TestReadSiteInfo(t *testing.T) {
+	si, err := ReadSiteInfo(strings.NewReader(testSiteInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, si.DBName, "dewiki")
+	assertEqual(t, len(si.Namespaces), 5)
+	assertEqual(t, si.Namespaces[2].Key, NamespaceFile)
+	assertEqual(t, si.Namespaces[2].Name, "Datei")
+
+	_, err = ReadSiteInfo(strings.NewReader("<mediawiki><page><title>Rom</title></page></mediawiki>"))
+	assertEqual(t, err, ErrNoSiteInfo)
+
+	lp := si.LinkParser()
+	links := lp.Parse("[[Datei:Rom.jpg]] [[Image:Rom.jpg]] [[Kategorie:Stadt]] [[Portal:Italien]] [[Rom]]")
+	assertEqual(t, len(links), 5)
+	assertEqual(t, links[0].Title(), "Datei:Rom.jpg")
+	assertEqual(t, links[1].Title(), "Datei:Rom.jpg")
+	assertEqual(t, links[2].Kind, LinkCategory)
+	assertEqual(t, links[3].Kind, LinkNamespace)
+	assertEqual(t, links[3].Namespace, 100)
+	assertEqual(t, links[4].Kind, LinkArticle)
+}
+
+func TestParseNamespaces(t *testing.T) {
+	for _, tt := range []struct {
+		list string
+		has  []int
+		not  []int
+	}{
+		{"main", []int{0}, []int{1, 14, 100}},
+		{"main, Portal", []int{0, 100}, []int{1, 14}},
+		{"0,100", []int{0, 100}, []int{1}},
+		{"all", []int{0, 1, 14, 100}, nil},
+		{"-talk,-user", []int{0, 14, 100}, []int{1, 2}},
+		{"all,-1", []int{0, 2}, []int{1}},
+		{"wp", []int{4}, []int{0}},
+	} {
+		nf, err := ParseNamespaces(tt.list, DefaultLinkParser)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.list, err)
+		}
+		for _, ns := range tt.has {
+			if !nf.Has(ns) {
+				t.Errorf("%q (%v) doesn't have namespace %d", tt.list, nf, ns)
+			}
+		}
+		for _, ns := range tt.not {
+			if nf.Has(ns) {
+				t.Errorf("%q (%v) has namespace %d", tt.list, nf, ns)
+			}
+		}
+	}
+
+	var nf *NamespaceFilter
+	assertEqual(t, nf.Has(NamespaceMain), true)
+	assertEqual(t, nf.Has(NamespaceCategory), false)
+
+	_, err := ParseNamespaces("main,nowhere", DefaultLinkParser)
+	assertEqual(t, err != nil, true)
+}
@@ -0,0 +1,125 @@
+package wikipath
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sitelinks are the pages on each wiki which Wikidata says are about the
+// same thing. They map a Wikidata item's number, like 64 for Q64, to the
+// title of its page on each site, by site ID like "dewiki".
+type Sitelinks map[int]map[string]string
+
+// add adds the page `title` on `site` to item `item`, if `sites` has it.
+func (sl Sitelinks) add(item int, site string, title string, sites map[string]bool) {
+	if sites != nil && !sites[site] || title == "" {
+		return
+	}
+	if sl[item] == nil {
+		sl[item] = make(map[string]string)
+	}
+	sl[item][site] = title
+}
+
+// WikiSite gets the site ID of the Wikipedia in a language, like "dewiki"
+// for "de", or "zh_yuewiki" for "zh-yue".
+func WikiSite(lang string) string {
+	return strings.Replace(lang, "-", "_", -1) + "wiki"
+}
+
+// SiteLanguage gets the language of a Wikipedia from its site ID, like "de"
+// for "dewiki". Returns "" for sites which aren't a Wikipedia.
+func SiteLanguage(site string) string {
+	if !strings.HasSuffix(site, "wiki") || len(site) == len("wiki") {
+		return ""
+	}
+	return strings.Replace(strings.TrimSuffix(site, "wiki"), "_", "-", -1)
+}
+
+// ReadSitelinksSQL reads sitelinks from a dump of Wikidata's
+// `wb_items_per_site` table. Only pages on `sites` are kept, or every page
+// if `sites` is nil, as the full table is very large.
+func ReadSitelinksSQL(r io.Reader, sites map[string]bool) (Sitelinks, error) {
+	sl := make(Sitelinks)
+	err := readSQLDump(r, func(row *SQLRow) error {
+		if row.Table != "wb_items_per_site" {
+			return nil
+		}
+		ints, err := sqlInts(row, "ips_item_id")
+		if err != nil {
+			return err
+		}
+		site, err := sqlString(row, "ips_site_id")
+		if err != nil {
+			return err
+		}
+		title, err := sqlString(row, "ips_site_page")
+		if err != nil {
+			return err
+		}
+		sl.add(ints[0], site, sqlTitle(title), sites)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sl, nil
+}
+
+// ReadSitelinksJSON reads sitelinks from JSON keyed by Wikidata item, with
+// the title on each site, like:
+//
+//	{"Q64": {"dewiki": "Berlin", "jawiki": "ベルリン"}}
+//
+// Sitelinks as the Wikidata API gives them also work, like
+// {"entities": {"Q64": {"sitelinks": {"dewiki": {"title": "Berlin"}}}}}.
+// Only pages on `sites` are kept, or every page if `sites` is nil.
+func ReadSitelinksJSON(r io.Reader, sites map[string]bool) (Sitelinks, error) {
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if entities, ok := doc["entities"]; ok && len(doc) == 1 {
+		doc = nil
+		if err := json.Unmarshal(entities, &doc); err != nil {
+			return nil, err
+		}
+	}
+
+	sl := make(Sitelinks)
+	for key, raw := range doc {
+		item, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(key), "Q"))
+		if err != nil {
+			return nil, fmt.Errorf("sitelinks: '%s' isn't a Wikidata item", key)
+		}
+
+		var pages map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &pages); err != nil {
+			return nil, fmt.Errorf("sitelinks: bad sitelinks for %s: %v", key, err)
+		}
+		if nested, ok := pages["sitelinks"]; ok {
+			pages = nil
+			if err := json.Unmarshal(nested, &pages); err != nil {
+				return nil, fmt.Errorf("sitelinks: bad sitelinks for %s: %v", key, err)
+			}
+		}
+
+		for site, page := range pages {
+			var title string
+			if err := json.Unmarshal(page, &title); err != nil {
+				var obj struct {
+					Title string `json:"title"`
+				}
+				if err := json.Unmarshal(page, &obj); err != nil {
+					return nil, fmt.Errorf("sitelinks: bad title for %s on %s", key, site)
+				}
+				title = obj.Title
+			}
+			sl.add(item, site, title, sites)
+		}
+	}
+	return sl, nil
+}
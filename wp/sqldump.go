@@ -0,0 +1,275 @@
+package wikipath
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SQLRow is one row inserted by a SQL dump.
+type SQLRow struct {
+	Table  string
+	Values []string // Unescaped values. NULL is "".
+
+	columns map[string]int
+}
+
+// Get returns the value of a column by name, and false if the table has no
+// such column.
+func (r *SQLRow) Get(column string) (string, bool) {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.Values) {
+		return "", false
+	}
+	return r.Values[i], true
+}
+
+// HasColumn returns true if the row's table has a column.
+func (r *SQLRow) HasColumn(column string) bool {
+	_, ok := r.columns[column]
+	return ok
+}
+
+// SQLDumpReader reads the rows out of a MySQL dump, like the `page.sql`
+// and `pagelinks.sql` tables Wikimedia publishes. Column names come from
+// the dump's CREATE TABLE statements, so it copes with columns being added
+// or reordered between MediaWiki versions.
+//
+// Only INSERT statements are parsed, and only as far as the next row, so
+// the multi-megabyte statements in real dumps are never held in memory.
+type SQLDumpReader struct {
+	r       *bufio.Reader
+	columns map[string]map[string]int // Table -> column name -> position.
+
+	table    string // Table of the INSERT being read, if any.
+	inInsert bool
+	val      bytes.Buffer
+}
+
+// NewSQLDumpReader creates an SQLDumpReader.
+func NewSQLDumpReader(r io.Reader) *SQLDumpReader {
+	return &SQLDumpReader{
+		r:       bufio.NewReaderSize(r, readerBufSize),
+		columns: make(map[string]map[string]int),
+	}
+}
+
+// ReadRow reads the next inserted row, returning EOF at the end of the dump.
+func (sr *SQLDumpReader) ReadRow() (*SQLRow, error) {
+	for !sr.inInsert {
+		if err := sr.statement(); err != nil {
+			return nil, err
+		}
+	}
+
+	cols, ok := sr.columns[sr.table]
+	if !ok {
+		return nil, fmt.Errorf("sql: no CREATE TABLE for `%s`", sr.table)
+	}
+
+	values, err := sr.tuple()
+	if err != nil {
+		return nil, noEOF(err)
+	}
+
+	// After a row comes another, or the end of the statement.
+	sep, err := sr.skipSpace()
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	switch sep {
+	case ',':
+	case ';':
+		sr.inInsert = false
+	default:
+		return nil, fmt.Errorf("sql: unexpected '%c' after row", sep)
+	}
+
+	return &SQLRow{Table: sr.table, Values: values, columns: cols}, nil
+}
+
+// statement reads up to the start of the next INSERT's rows, picking up
+// column names from any CREATE TABLE on the way.
+func (sr *SQLDumpReader) statement() error {
+	peek, _ := sr.r.Peek(len("INSERT INTO "))
+	if string(peek) == "INSERT INTO " {
+		// Everything up to the first row's '('.
+		head, err := sr.r.ReadString('(')
+		if err != nil {
+			return noEOF(err)
+		}
+		sr.table = sqlTableName(head)
+		sr.inInsert = true
+		return sr.r.UnreadByte()
+	}
+
+	line, err := sr.r.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return EOF
+	} else if err != nil && err != io.EOF {
+		return err
+	}
+
+	if strings.HasPrefix(line, "CREATE TABLE ") {
+		return sr.createTable(sqlTableName(line))
+	}
+	return nil
+}
+
+// createTable reads the column definitions of a CREATE TABLE, one per line.
+func (sr *SQLDumpReader) createTable(table string) error {
+	cols := make(map[string]int)
+	for {
+		line, err := sr.r.ReadString('\n')
+		if err != nil {
+			return noEOF(err)
+		}
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, ")") {
+			break
+		}
+		if strings.HasPrefix(line, "`") {
+			if end := strings.IndexByte(line[1:], '`'); end >= 0 {
+				cols[line[1:end+1]] = len(cols)
+			}
+		}
+	}
+	sr.columns[table] = cols
+	return nil
+}
+
+// sqlTableName gets the first backquoted name in a statement.
+func sqlTableName(stmt string) string {
+	start := strings.IndexByte(stmt, '`')
+	if start < 0 {
+		return ""
+	}
+	end := strings.IndexByte(stmt[start+1:], '`')
+	if end < 0 {
+		return ""
+	}
+	return stmt[start+1 : start+1+end]
+}
+
+// skipSpace returns the next byte which isn't whitespace.
+func (sr *SQLDumpReader) skipSpace() (byte, error) {
+	for {
+		c, err := sr.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\n' && c != '\r' && c != '\t' {
+			return c, nil
+		}
+	}
+}
+
+// tuple reads one parenthesized row of values.
+func (sr *SQLDumpReader) tuple() ([]string, error) {
+	c, err := sr.skipSpace()
+	if err != nil {
+		return nil, err
+	} else if c != '(' {
+		return nil, fmt.Errorf("sql: expected '(', got '%c'", c)
+	}
+
+	var values []string
+	for {
+		value, end, err := sr.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if end == ')' {
+			return values, nil
+		}
+	}
+}
+
+// value reads one value of a row and the ',' or ')' after it.
+func (sr *SQLDumpReader) value() (string, byte, error) {
+	sr.val.Reset()
+
+	c, err := sr.skipSpace()
+	if err != nil {
+		return "", 0, err
+	}
+
+	if c == '\'' {
+		if err := sr.quoted(); err != nil {
+			return "", 0, err
+		}
+		c, err = sr.skipSpace()
+		if err != nil {
+			return "", 0, err
+		}
+	} else {
+		// A number, or NULL.
+		for c != ',' && c != ')' {
+			sr.val.WriteByte(c)
+			if c, err = sr.r.ReadByte(); err != nil {
+				return "", 0, err
+			}
+		}
+		value := strings.TrimSpace(sr.val.String())
+		if value == "NULL" {
+			value = ""
+		}
+		return value, c, nil
+	}
+
+	if c != ',' && c != ')' {
+		return "", 0, fmt.Errorf("sql: unexpected '%c' after value", c)
+	}
+	return sr.val.String(), c, nil
+}
+
+// quoted reads the rest of a single-quoted string, unescaping it.
+func (sr *SQLDumpReader) quoted() error {
+	for {
+		c, err := sr.r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch c {
+		case '\'':
+			// A doubled quote is an escaped one.
+			if next, _ := sr.r.Peek(1); len(next) == 1 && next[0] == '\'' {
+				sr.r.ReadByte()
+				sr.val.WriteByte('\'')
+				continue
+			}
+			return nil
+		case '\\':
+			esc, err := sr.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			sr.val.WriteByte(sqlUnescape(esc))
+		default:
+			sr.val.WriteByte(c)
+		}
+	}
+}
+
+func sqlUnescape(c byte) byte {
+	switch c {
+	case '0':
+		return 0
+	case 'b':
+		return '\b'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'Z':
+		return 0x1a
+	default:
+		return c // \\, \', \" and anything else.
+	}
+}
@@ -0,0 +1,166 @@
+package wikipath
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pageSQL = "-- MySQL dump 10.19\n" +
+	"/*!40101 SET NAMES binary*/;\n" +
+	"DROP TABLE IF EXISTS `page`;\n" +
+	"CREATE TABLE `page` (\n" +
+	"  `page_id` int(8) unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `page_namespace` int(11) NOT NULL DEFAULT 0,\n" +
+	"  `page_title` varbinary(255) NOT NULL DEFAULT '',\n" +
+	"  `page_is_redirect` tinyint(1) unsigned NOT NULL DEFAULT 0,\n" +
+	"  `page_touched` binary(14) NOT NULL,\n" +
+	"  `page_latest` int(8) unsigned NOT NULL,\n" +
+	"  `page_len` int(8) unsigned NOT NULL DEFAULT 0,\n" +
+	"  PRIMARY KEY (`page_id`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=binary;\n" +
+	"INSERT INTO `page` VALUES (1,0,'Queen_(band)',0,'20240501123000',1200,120),(2,0,'Freddie_Mercury',0,'20240502000000',1300,80)," +
+	"(3,1,'Queen_(band)',0,'20240501123000',1201,10),(4,0,'Queen_band',1,'20231225080910',900,20)," +
+	"(5,0,'Don\\'t_Stop_Me_Now',0,'',1400,NULL);\n" +
+	"INSERT INTO `page` VALUES (6,0,'Brian_May',0,'20240503000000',1500,30),(7,0,'Help:Contents',1,'20240503000000',1600,5);\n" +
+	"UNLOCK TABLES;\n"
+
+const redirectSQL = "CREATE TABLE `redirect` (\n" +
+	"  `rd_from` int(8) unsigned NOT NULL DEFAULT 0,\n" +
+	"  `rd_namespace` int(11) NOT NULL DEFAULT 0,\n" +
+	"  `rd_title` varbinary(255) NOT NULL DEFAULT '',\n" +
+	"  `rd_interwiki` varbinary(32) DEFAULT NULL,\n" +
+	"  `rd_fragment` varbinary(255) DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`rd_from`)\n" +
+	");\n" +
+	"INSERT INTO `redirect` VALUES (4,0,'Queen_(band)','',''),(7,12,'Contents',NULL,NULL);\n"
+
+const pagelinksSQL = "CREATE TABLE `pagelinks` (\n" +
+	"  `pl_from` int(8) unsigned NOT NULL DEFAULT 0,\n" +
+	"  `pl_namespace` int(11) NOT NULL DEFAULT 0,\n" +
+	"  `pl_title` varbinary(255) NOT NULL DEFAULT '',\n" +
+	"  `pl_from_namespace` int(11) NOT NULL DEFAULT 0\n" +
+	");\n" +
+	"INSERT INTO `pagelinks` VALUES (1,0,'Brian_May',0),(1,0,'Freddie_Mercury',0),(1,10,'Infobox',0)," +
+	"(3,0,'Queen_(band)',1),(5,0,'Queen_band',0);\n"
+
+// Newer pagelinks dumps refer to titles in a separate linktarget table.
+const linktargetSQL = "CREATE TABLE `linktarget` (\n" +
+	"  `lt_id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `lt_namespace` int(11) NOT NULL,\n" +
+	"  `lt_title` varbinary(255) NOT NULL,\n" +
+	"  PRIMARY KEY (`lt_id`)\n" +
+	");\n" +
+	"INSERT INTO `linktarget` VALUES (10,0,'Brian_May'),(11,0,'Freddie_Mercury'),(12,10,'Infobox'),(13,0,'Queen_band');\n"
+
+const pagelinksTargetSQL = "CREATE TABLE `pagelinks` (\n" +
+	"  `pl_from` int(8) unsigned NOT NULL DEFAULT 0,\n" +
+	"  `pl_from_namespace` int(11) NOT NULL DEFAULT 0,\n" +
+	"  `pl_target_id` bigint(20) unsigned NOT NULL\n" +
+	");\n" +
+	"INSERT INTO `pagelinks` VALUES (1,0,10),(1,0,11),(1,0,12),(5,0,13);\n"
+
+func TestSQLDumpReader(t *testing.T) {
+	sr := NewSQLDumpReader(strings.NewReader(pageSQL))
+
+	var rows []*SQLRow
+	for {
+		row, err := sr.ReadRow()
+		if err == EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+
+	assertEqual(t, len(rows), 7)
+	assertEqual(t, rows[0].Table, "page")
+	assertEqual(t, reflect.DeepEqual(rows[0].Values, []string{"1", "0", "Queen_(band)", "0", "20240501123000", "1200", "120"}), true)
+
+	title, ok := rows[4].Get("page_title")
+	assertEqual(t, title, "Don't_Stop_Me_Now")
+	assertEqual(t, ok, true)
+	length, _ := rows[4].Get("page_len")
+	assertEqual(t, length, "")
+
+	_, ok = rows[0].Get("page_lang")
+	assertEqual(t, ok, false)
+}
+
+func TestSQLDumpValues(t *testing.T) {
+	dump := "CREATE TABLE `t` (\n  `a` text,\n  `b` text\n);\n" +
+		"INSERT INTO `t` VALUES ('it''s','a\\\\b\\nc'),( 'x,y' , ')' );\n"
+	sr := NewSQLDumpReader(strings.NewReader(dump))
+
+	row, err := sr.ReadRow()
+	assertEqual(t, err, nil)
+	assertEqual(t, reflect.DeepEqual(row.Values, []string{"it's", "a\\b\nc"}), true)
+
+	row, err = sr.ReadRow()
+	assertEqual(t, err, nil)
+	assertEqual(t, reflect.DeepEqual(row.Values, []string{"x,y", ")"}), true)
+
+	_, err = sr.ReadRow()
+	assertEqual(t, err, EOF)
+
+	// Inserting into a table which was never created.
+	_, err = NewSQLDumpReader(strings.NewReader("INSERT INTO `u` VALUES (1);\n")).ReadRow()
+	if err == nil {
+		t.Fatal("expected an error for a table without columns")
+	}
+}
+
+func importSQL(t *testing.T, dumps SQLDumps) []*StrippedArticle {
+	var articles []*StrippedArticle
+	err := ImportSQLDumps(dumps, func(sa *StrippedArticle) bool {
+		articles = append(articles, sa)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return articles
+}
+
+var sqlArticles = []*StrippedArticle{
+	{Title: "Queen (band)", ID: 1, Size: 120, RevisionID: 1200, Links: []string{"Brian May", "Freddie Mercury"}},
+	{Title: "Freddie Mercury", ID: 2, Size: 80, RevisionID: 1300},
+	{Title: "Queen band", ID: 4, Size: 20, RevisionID: 900, Redirect: "Queen (band)"},
+	{Title: "Don't Stop Me Now", ID: 5, RevisionID: 1400, Links: []string{"Queen band"}}, // page_len is NULL
+	{Title: "Brian May", ID: 6, Size: 30, RevisionID: 1500},
+}
+
+func TestImportSQLDumps(t *testing.T) {
+	articles := importSQL(t, SQLDumps{
+		Page:      strings.NewReader(pageSQL),
+		Redirect:  strings.NewReader(redirectSQL),
+		PageLinks: strings.NewReader(pagelinksSQL),
+	})
+	if !reflect.DeepEqual(articles, sqlArticles) {
+		t.Fatalf("got %+v", articles)
+	}
+}
+
+func TestImportSQLDumpsLinkTarget(t *testing.T) {
+	articles := importSQL(t, SQLDumps{
+		Page:       strings.NewReader(pageSQL),
+		Redirect:   strings.NewReader(redirectSQL),
+		PageLinks:  strings.NewReader(pagelinksTargetSQL),
+		LinkTarget: strings.NewReader(linktargetSQL),
+	})
+	if !reflect.DeepEqual(articles, sqlArticles) {
+		t.Fatalf("got %+v", articles)
+	}
+}
+
+func TestImportSQLDumpsUnsorted(t *testing.T) {
+	unsorted := strings.Replace(pagelinksSQL, "(5,0,'Queen_band',0)", "(2,0,'Queen_band',0)", 1)
+	err := ImportSQLDumps(SQLDumps{
+		Page:      strings.NewReader(pageSQL),
+		PageLinks: strings.NewReader(unsorted),
+	}, func(*StrippedArticle) bool { return true })
+	if err == nil {
+		t.Fatal("expected an error for unsorted pagelinks")
+	}
+}
@@ -0,0 +1,246 @@
+package wikipath
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SQLDumps are the MediaWiki table dumps to import articles from, as
+// published next to the XML archives (`*-page.sql.gz` and so on).
+type SQLDumps struct {
+	Page      io.Reader // page.sql: titles and IDs. Required.
+	PageLinks io.Reader // pagelinks.sql: the links. Required.
+	Redirect  io.Reader // redirect.sql: redirect targets. Without it, redirects are left out.
+
+	// LinkTarget is linktarget.sql, which newer pagelinks dumps refer to
+	// by `pl_target_id` rather than giving the title of each link.
+	LinkTarget io.Reader
+}
+
+// sqlTitle turns a title as stored in the database into a page title.
+func sqlTitle(dbKey string) string {
+	return strings.Replace(dbKey, "_", " ", -1)
+}
+
+// sqlInts parses the named integer columns of a row.
+func sqlInts(row *SQLRow, columns ...string) ([]int, error) {
+	ints := make([]int, len(columns))
+	for i, col := range columns {
+		val, ok := row.Get(col)
+		if !ok {
+			return nil, fmt.Errorf("sql: table `%s` has no column `%s`", row.Table, col)
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("sql: bad `%s` in `%s`: %v", col, row.Table, err)
+		}
+		ints[i] = n
+	}
+	return ints, nil
+}
+
+// sqlString gets a named column of a row.
+func sqlString(row *SQLRow, column string) (string, error) {
+	val, ok := row.Get(column)
+	if !ok {
+		return "", fmt.Errorf("sql: table `%s` has no column `%s`", row.Table, column)
+	}
+	return val, nil
+}
+
+// readSQLDump calls `fn` for every row of a dump.
+func readSQLDump(r io.Reader, fn func(*SQLRow) error) error {
+	sr := NewSQLDumpReader(r)
+	for {
+		row, err := sr.ReadRow()
+		if err == EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// ImportSQLDumps reads articles from MediaWiki's SQL table dumps rather than
+// from the XML archive, calling `visitor` for each in page ID order, and
+// stopping if it returns false. This skips parsing any wikitext, which makes
+// it much quicker than LoadWiki, but links come out in title order rather
+// than the order they appear in the article. Articles have no Timestamp, as
+// the page table doesn't say when their revision was made.
+//
+// Only the main namespace is imported. The page and redirect tables are held
+// in memory, and pagelinks is streamed, which relies on it being sorted by
+// `pl_from` like the published dumps are.
+func ImportSQLDumps(dumps SQLDumps, visitor func(*StrippedArticle) bool) error {
+	pages := make(map[int]*StrippedArticle)
+	redirects := make(map[int]bool) // Pages which are redirects, found or not.
+
+	err := readSQLDump(dumps.Page, func(row *SQLRow) error {
+		vals, err := sqlInts(row, "page_id", "page_namespace", "page_is_redirect")
+		if err != nil {
+			return err
+		}
+		if vals[1] != 0 {
+			return nil
+		}
+		title, err := sqlString(row, "page_title")
+		if err != nil {
+			return err
+		}
+
+		// The latest revision and its length are left out if the dump doesn't
+		// have them, or has them as NULL. The page table has no time for the
+		// revision, only `page_touched`, which also changes when the page is
+		// re-rendered, so Timestamp is left zero.
+		sa := &StrippedArticle{Title: sqlTitle(title), ID: vals[0]}
+		if size, err := sqlInts(row, "page_len"); err == nil {
+			sa.Size = size[0]
+		}
+		if rev, err := sqlInts(row, "page_latest"); err == nil {
+			sa.RevisionID = rev[0]
+		}
+		pages[vals[0]] = sa
+		if vals[2] != 0 {
+			redirects[vals[0]] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading page dump: %v", err)
+	}
+
+	if dumps.Redirect != nil {
+		err := readSQLDump(dumps.Redirect, func(row *SQLRow) error {
+			vals, err := sqlInts(row, "rd_from", "rd_namespace")
+			if err != nil {
+				return err
+			}
+			title, err := sqlString(row, "rd_title")
+			if err != nil {
+				return err
+			}
+			if sa := pages[vals[0]]; sa != nil && vals[1] == 0 {
+				sa.Redirect = sqlTitle(title)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("reading redirect dump: %v", err)
+		}
+	}
+
+	// Redirects without a target in the main namespace can't be followed.
+	for id := range redirects {
+		if pages[id].Redirect == "" {
+			delete(pages, id)
+		}
+	}
+
+	var targets map[int]string // Link target ID -> title.
+	if dumps.LinkTarget != nil {
+		targets = make(map[int]string)
+		err := readSQLDump(dumps.LinkTarget, func(row *SQLRow) error {
+			vals, err := sqlInts(row, "lt_id", "lt_namespace")
+			if err != nil {
+				return err
+			}
+			title, err := sqlString(row, "lt_title")
+			if err != nil {
+				return err
+			}
+			if vals[1] == 0 {
+				targets[vals[0]] = sqlTitle(title)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("reading linktarget dump: %v", err)
+		}
+	}
+
+	ids := make([]int, 0, len(pages))
+	for id := range pages {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	// Visit each article once pagelinks has moved past it.
+	next := 0
+	visitUntil := func(id int) error {
+		for ; next < len(ids) && ids[next] < id; next++ {
+			sa := pages[ids[next]]
+			delete(pages, ids[next])
+			if !visitor(sa) {
+				return ErrStopped
+			}
+		}
+		return nil
+	}
+
+	lastFrom := 0
+	err = readSQLDump(dumps.PageLinks, func(row *SQLRow) error {
+		vals, err := sqlInts(row, "pl_from")
+		if err != nil {
+			return err
+		}
+		from := vals[0]
+		if from < lastFrom {
+			return fmt.Errorf("pagelinks isn't sorted by pl_from (%d after %d)", from, lastFrom)
+		}
+		lastFrom = from
+
+		if err := visitUntil(from); err != nil {
+			return err
+		}
+		sa := pages[from]
+		if sa == nil || sa.Redirect != "" {
+			return nil
+		}
+
+		var link string
+		if row.HasColumn("pl_target_id") {
+			if targets == nil {
+				return fmt.Errorf("pagelinks refers to link targets, but there's no linktarget dump")
+			}
+			vals, err := sqlInts(row, "pl_target_id")
+			if err != nil {
+				return err
+			}
+			link = targets[vals[0]]
+		} else {
+			vals, err := sqlInts(row, "pl_namespace")
+			if err != nil {
+				return err
+			}
+			title, err := sqlString(row, "pl_title")
+			if err != nil {
+				return err
+			}
+			if vals[0] == 0 {
+				link = sqlTitle(title)
+			}
+		}
+
+		if link != "" {
+			sa.Links = append(sa.Links, link)
+		}
+		return nil
+	})
+	if err == ErrStopped {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("reading pagelinks dump: %v", err)
+	}
+
+	// Whatever's left has no links.
+	if next < len(ids) {
+		return visitUntil(ids[len(ids)-1] + 1)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package wikipath
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// IndexStats describe the articles in an Index and the links between them,
+// for finding problems with a dump or how it was indexed.
+type IndexStats struct {
+	Articles        int `json:"articles"`         // Pages in the index, not counting redirects.
+	Redirects       int `json:"redirects"`        // Titles which redirect to a page in the index.
+	BrokenRedirects int `json:"broken_redirects"` // Redirects to pages which aren't in the index.
+
+	Edges          int `json:"edges"`           // Links between pages, counting each pair once.
+	RedLinks       int `json:"red_links"`       // Links to pages which aren't in the index.
+	DuplicateLinks int `json:"duplicate_links"` // Links to a page an article has already linked to.
+	SelfLinks      int `json:"self_links"`      // Articles which link to themselves.
+
+	Orphans  int `json:"orphans"`   // Articles no other article links to.
+	DeadEnds int `json:"dead_ends"` // Articles which link to no other article.
+
+	InDegree  DegreeStats `json:"in_degree"`  // How many articles link to each article.
+	OutDegree DegreeStats `json:"out_degree"` // How many articles each article links to.
+
+	MostLinked []LinkedItem `json:"most_linked"` // The articles most linked to, most first.
+}
+
+// DegreeStats describe how many links each article has, in or out.
+type DegreeStats struct {
+	Mean   float64 `json:"mean"`
+	Median int     `json:"median"`
+	P90    int     `json:"p90"`
+	P99    int     `json:"p99"`
+	Max    int     `json:"max"`
+
+	// Histogram counts the articles with 0 links, 1, 2-3, 4-7, and so on,
+	// doubling each time.
+	Histogram []int `json:"histogram"`
+}
+
+// LinkedItem is an article, and how many articles link to it.
+type LinkedItem struct {
+	Title string `json:"title"`
+	Links int    `json:"links"`
+}
+
+// Stats counts the articles, redirects and links in the index, and how
+// links are spread between articles, with the `top` most linked articles,
+// or none if `top` is negative. Links from an article to itself don't count
+// towards its degree.
+func (ind *Index) Stats(top int) *IndexStats {
+	if !ind.ready {
+		ind.Build()
+	}
+
+	items := ind.Items()
+	st := &IndexStats{
+		Articles:        len(items),
+		BrokenRedirects: ind.brokenRedirects,
+		RedLinks:        int(atomic.LoadInt64(&ind.redLinks)),
+		DuplicateLinks:  int(atomic.LoadInt64(&ind.duplicateLinks)),
+	}
+	ind.itemIndexMut.RLock()
+	st.Redirects = len(ind.itemIndex) - len(items)
+	ind.itemIndexMut.RUnlock()
+
+	in := make([]int, len(items))
+	out := make([]int, len(items))
+	for i, item := range items {
+		st.Edges += len(item.Forward)
+		in[i], out[i] = len(item.Reverse), len(item.Forward)
+		if hasItem(item.Forward, item) {
+			st.SelfLinks++
+			in[i]--
+			out[i]--
+		}
+		if in[i] == 0 {
+			st.Orphans++
+		}
+		if out[i] == 0 {
+			st.DeadEnds++
+		}
+	}
+
+	// The most linked articles, with ties in title order like Items.
+	linked := make([]LinkedItem, len(items))
+	for i, item := range items {
+		linked[i] = LinkedItem{Title: item.Title, Links: in[i]}
+	}
+	sort.SliceStable(linked, func(i, j int) bool { return linked[i].Links > linked[j].Links })
+	if top < 0 {
+		top = 0
+	}
+	if top < len(linked) {
+		linked = linked[:top]
+	}
+	st.MostLinked = linked
+
+	st.InDegree = degreeStats(in)
+	st.OutDegree = degreeStats(out)
+	return st
+}
+
+// degreeStats describes a list of degrees, sorting it.
+func degreeStats(degrees []int) DegreeStats {
+	var ds DegreeStats
+	if len(degrees) == 0 {
+		return ds
+	}
+	sort.Ints(degrees)
+
+	total := 0
+	for _, d := range degrees {
+		total += d
+		bucket := 0
+		for n := d; n > 0; n >>= 1 {
+			bucket++
+		}
+		for len(ds.Histogram) <= bucket {
+			ds.Histogram = append(ds.Histogram, 0)
+		}
+		ds.Histogram[bucket]++
+	}
+	ds.Mean = float64(total) / float64(len(degrees))
+	ds.Median = percentile(degrees, 50)
+	ds.P90 = percentile(degrees, 90)
+	ds.P99 = percentile(degrees, 99)
+	ds.Max = degrees[len(degrees)-1]
+	return ds
+}
+
+// percentile gets the p'th percentile of a sorted list, by nearest rank.
+func percentile(sorted []int, p int) int {
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,45 @@
+package wikipath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	index := NewIndex()
+	for _, a := range []*Article{
+		{Title: "A", Text: "[[B]] [[b]] [[Missing]] [[A]]"},
+		{Title: "B", Text: "[[C]]"},
+		{Title: "C", Text: ""},
+		{Title: "D", Text: "[[C]]"},
+		{Title: "R", Redirect: Redirect{Title: "B"}},
+		{Title: "X", Redirect: Redirect{Title: "Nowhere"}},
+	} {
+		index.AddArticle(NewStrippedArticle(a))
+	}
+
+	st := index.Stats(2)
+	assertEqual(t, st.Articles, 4)
+	assertEqual(t, st.Redirects, 1)
+	assertEqual(t, st.BrokenRedirects, 1)
+	assertEqual(t, st.Edges, 4)
+	assertEqual(t, st.RedLinks, 1)
+	assertEqual(t, st.DuplicateLinks, 1)
+	assertEqual(t, st.SelfLinks, 1)
+	assertEqual(t, st.Orphans, 2)  // A, which only links to itself, and D.
+	assertEqual(t, st.DeadEnds, 1) // C.
+
+	assertEqual(t, st.InDegree.Mean, 0.75)
+	assertEqual(t, st.InDegree.Median, 0)
+	assertEqual(t, st.InDegree.P90, 2)
+	assertEqual(t, st.InDegree.Max, 2)
+	assertEqual(t, reflect.DeepEqual(st.InDegree.Histogram, []int{2, 1, 1}), true)
+	assertEqual(t, st.OutDegree.Median, 1)
+	assertEqual(t, reflect.DeepEqual(st.MostLinked, []LinkedItem{{"C", 2}, {"B", 1}}), true)
+	assertEqual(t, len(index.Stats(-1).MostLinked), 0)
+
+	// An empty index has no articles to describe.
+	st = NewIndex().Stats(10)
+	assertEqual(t, st.Articles, 0)
+	assertEqual(t, len(st.MostLinked), 0)
+}
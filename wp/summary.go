@@ -0,0 +1,183 @@
+package wikipath
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A summaries file holds the lead paragraph of each article, keyed by page
+// ID, next to a *.wpindex file. It isn't compressed, so each summary can be
+// read on its own:
+//
+//   header:  magic "\x89WPS", version byte
+//   records: varint page ID, uvarint length, summary
+//   table:   (int64 page ID, int64 record offset) for each record, sorted
+//            by page ID, little endian
+//   trailer: int64 table offset, int64 records, magic "WPS\x89"
+
+const summaryMagic = "\x89WPS"
+const summaryTrailerMagic = "WPS\x89"
+const summaryVersion byte = 1
+const summaryTrailerSize = 8 + 8 + len(summaryTrailerMagic)
+
+// ErrNoSummary is returned when an article has no summary.
+var ErrNoSummary = errors.New("no summary for article")
+
+// SummariesPath gets the path of the summaries file for a *.wpindex file.
+func SummariesPath(wpindexPath string) string {
+	return wpindexPath + ".summaries"
+}
+
+// summaryEntry is where the summary of one page is.
+type summaryEntry struct {
+	ID     int64
+	Offset int64
+}
+
+// SummaryWriter writes a summaries file.
+type SummaryWriter struct {
+	w       *bufio.Writer
+	offset  int64
+	entries []summaryEntry
+	seen    map[int]bool
+}
+
+// NewSummaryWriter creates a SummaryWriter, writing the header to `w`.
+func NewSummaryWriter(w io.Writer) (*SummaryWriter, error) {
+	sw := &SummaryWriter{w: bufio.NewWriter(w), seen: make(map[int]bool)}
+	if err := sw.write(append([]byte(summaryMagic), summaryVersion)); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *SummaryWriter) write(buf []byte) error {
+	n, err := sw.w.Write(buf)
+	sw.offset += int64(n)
+	return err
+}
+
+// Write adds the summary of page `id`. Empty summaries, and ones for pages
+// which already have one, are left out.
+func (sw *SummaryWriter) Write(id int, summary string) error {
+	if summary == "" || sw.seen[id] {
+		return nil
+	}
+	sw.seen[id] = true
+	sw.entries = append(sw.entries, summaryEntry{ID: int64(id), Offset: sw.offset})
+
+	rec := binary.AppendVarint(nil, int64(id))
+	rec = appendString(rec, summary)
+	return sw.write(rec)
+}
+
+// Len gets how many summaries have been written.
+func (sw *SummaryWriter) Len() int {
+	return len(sw.entries)
+}
+
+// Close writes the table of summaries, and flushes the file. It doesn't
+// close the underlying io.Writer.
+func (sw *SummaryWriter) Close() error {
+	sort.Slice(sw.entries, func(i, j int) bool { return sw.entries[i].ID < sw.entries[j].ID })
+
+	tableOffset := sw.offset
+	var buf []byte
+	for _, e := range sw.entries {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.ID))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.Offset))
+		if len(buf) >= 1<<16 {
+			if err := sw.write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(tableOffset))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(sw.entries)))
+	buf = append(buf, summaryTrailerMagic...)
+	if err := sw.write(buf); err != nil {
+		return err
+	}
+	return sw.w.Flush()
+}
+
+// Summaries reads the summaries in a summaries file, as they're asked for.
+type Summaries struct {
+	r       io.ReaderAt
+	entries []summaryEntry
+}
+
+// OpenSummaries opens a summaries file of `size` bytes, reading its table.
+func OpenSummaries(r io.ReaderAt, size int64) (*Summaries, error) {
+	header := make([]byte, len(summaryMagic)+1)
+	if _, err := r.ReadAt(header, 0); err != nil || string(header[:len(summaryMagic)]) != summaryMagic {
+		return nil, fmt.Errorf("not a summaries file")
+	}
+	if header[len(summaryMagic)] != summaryVersion {
+		return nil, fmt.Errorf("unsupported summaries version %d", header[len(summaryMagic)])
+	}
+
+	trailer := make([]byte, summaryTrailerSize)
+	if size < int64(len(header)+summaryTrailerSize) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if _, err := r.ReadAt(trailer, size-int64(summaryTrailerSize)); err != nil {
+		return nil, err
+	}
+	if string(trailer[16:]) != summaryTrailerMagic {
+		return nil, fmt.Errorf("summaries file has no table, it may be truncated")
+	}
+	tableOffset := int64(binary.LittleEndian.Uint64(trailer))
+	count := int64(binary.LittleEndian.Uint64(trailer[8:]))
+	if tableOffset < int64(len(header)) || count < 0 || tableOffset+count*16 != size-int64(summaryTrailerSize) {
+		return nil, ErrCorrupt
+	}
+
+	table := make([]byte, count*16)
+	if _, err := r.ReadAt(table, tableOffset); err != nil {
+		return nil, err
+	}
+	s := &Summaries{r: r, entries: make([]summaryEntry, count)}
+	for i := range s.entries {
+		s.entries[i].ID = int64(binary.LittleEndian.Uint64(table[i*16:]))
+		s.entries[i].Offset = int64(binary.LittleEndian.Uint64(table[i*16+8:]))
+		if s.entries[i].Offset >= tableOffset {
+			return nil, ErrCorrupt
+		}
+	}
+	return s, nil
+}
+
+// Len gets how many summaries there are.
+func (s *Summaries) Len() int {
+	return len(s.entries)
+}
+
+// Get gets the summary of page `id`, or ErrNoSummary if it hasn't got one.
+func (s *Summaries) Get(id int) (string, error) {
+	i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].ID >= int64(id) })
+	if i == len(s.entries) || s.entries[i].ID != int64(id) {
+		return "", ErrNoSummary
+	}
+
+	// Read the record's header, then as much more as it says there is.
+	r := bufio.NewReader(io.NewSectionReader(s.r, s.entries[i].Offset, 1<<62))
+	recID, err := binary.ReadVarint(r)
+	if err != nil || recID != int64(id) {
+		return "", ErrCorrupt
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(maxBlockBytes) {
+		return "", ErrCorrupt
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", ErrCorrupt
+	}
+	return string(buf), nil
+}
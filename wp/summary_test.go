@@ -0,0 +1,47 @@
+package wikipath
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSummaries(t *testing.T) {
+	var buf bytes.Buffer
+	sw, err := NewSummaryWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []struct {
+		id      int
+		summary string
+	}{{42, "Queen are a British rock band."}, {7, "Paris is the capital of France."}, {9, ""}, {42, "A second summary."}} {
+		if err := sw.Write(s.id, s.summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenSummaries(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, s.Len(), 2)
+
+	summary, err := s.Get(42)
+	assertEqual(t, err, nil)
+	assertEqual(t, summary, "Queen are a British rock band.")
+	summary, err = s.Get(7)
+	assertEqual(t, err, nil)
+	assertEqual(t, summary, "Paris is the capital of France.")
+
+	_, err = s.Get(9) // Empty summaries aren't kept.
+	assertEqual(t, err, ErrNoSummary)
+	_, err = s.Get(1000)
+	assertEqual(t, err, ErrNoSummary)
+
+	// A file cut short is noticed.
+	_, err = OpenSummaries(bytes.NewReader(buf.Bytes()[:buf.Len()-3]), int64(buf.Len()-3))
+	assertEqual(t, err != nil, true)
+}
@@ -0,0 +1,639 @@
+package wikipath
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// The compact *.wpindex format is a sequence of blocks inside the same gzip
+// stream the gob format uses:
+//
+//   header:  magic "\x89WPX", version byte
+//   block:   uvarint records (>0), uvarint dict size, dict strings,
+//            uvarint payload length, payload, crc32
+//   footer:  uvarint 0, uvarint total records, uvarint blocks, crc32
+//
+// Each block has its own dictionary of link targets, and each record in the
+// payload is length-prefixed so a damaged record can be skipped. Strings are
+// a uvarint length followed by the bytes. The crc32 (IEEE, little endian)
+// covers every byte of the block or footer before it.
+
+// compactMagic starts every compact stream. 0x89 can never be the first byte
+// of a gob stream, which is how readers tell the formats apart.
+const compactMagic = "\x89WPX"
+
+const compactVersion byte = 1
+
+const blockRecords int = 512        // Max records per block.
+const blockBytes int = 1 << 20      // Flush a block once its payload gets this big.
+const maxBlockBytes int = 256 << 20 // Upper bound on any length field, to survive garbage.
+
+// ErrCorrupt is returned when a *.wpindex file can't be decoded.
+var ErrCorrupt = errors.New("corrupt .wpindex data")
+
+// ErrChecksum is returned when a block of a *.wpindex file fails its checksum.
+var ErrChecksum = errors.New(".wpindex block checksum mismatch")
+
+// Record extension tags. A record ends with a list of (tag, length, bytes)
+// fields terminated by a 0 tag; readers skip tags they don't know.
+const (
+	tagEnd            uint64 = iota
+	tagEdges                 // The EdgeType of each link, one byte each.
+	tagFlags                 // The LinkFlags of each link, one byte each.
+	tagAnchors               // The text of each link, as strings.
+	tagContexts              // The sentence of each link, as strings.
+	tagNamespace             // The namespace of the page, as a varint.
+	tagCategories            // The titles of the page's categories, as strings.
+	tagDisambiguation        // The page is a disambiguation page. Always empty.
+	tagRevision              // The revision ID, timestamp in Unix seconds (0 if unknown), and size.
+	tagDescription           // The page's short description.
+	tagInterwiki             // The prefix and target of each interwiki link, as strings.
+	tagFragments             // The section each link goes to, as strings.
+	tagSections              // The heading of the section each link is in, as strings.
+)
+
+// compactEncoder writes `StrippedArticle`s in the compact format.
+type compactEncoder struct {
+	w io.Writer
+
+	dict     map[string]int
+	dictList []string
+	payload  bytes.Buffer
+	records  int
+
+	total  uint64
+	blocks uint64
+
+	scratch []byte
+}
+
+func newCompactEncoder(w io.Writer) (*compactEncoder, error) {
+	header := append([]byte(compactMagic), compactVersion)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &compactEncoder{
+		w:    w,
+		dict: make(map[string]int),
+	}, nil
+}
+
+func (ce *compactEncoder) encode(a *StrippedArticle) error {
+	rec := ce.scratch[:0]
+	rec = appendString(rec, a.Title)
+	rec = binary.AppendVarint(rec, int64(a.ID))
+	rec = appendString(rec, a.Redirect)
+	rec = binary.AppendUvarint(rec, uint64(len(a.Links)))
+	for _, l := range a.Links {
+		i, ok := ce.dict[l]
+		if !ok {
+			i = len(ce.dictList)
+			ce.dict[l] = i
+			ce.dictList = append(ce.dictList, l)
+		}
+		rec = binary.AppendUvarint(rec, uint64(i))
+	}
+	if len(a.Edges) > 0 {
+		rec = binary.AppendUvarint(rec, tagEdges)
+		rec = binary.AppendUvarint(rec, uint64(len(a.Edges)))
+		for _, e := range a.Edges {
+			rec = append(rec, byte(e))
+		}
+	}
+	if len(a.Flags) > 0 {
+		rec = binary.AppendUvarint(rec, tagFlags)
+		rec = binary.AppendUvarint(rec, uint64(len(a.Flags)))
+		for _, f := range a.Flags {
+			rec = append(rec, byte(f))
+		}
+	}
+	rec = appendStrings(rec, tagAnchors, a.Anchors)
+	rec = appendStrings(rec, tagContexts, a.Contexts)
+	if a.Namespace != NamespaceMain {
+		ns := binary.AppendVarint(nil, int64(a.Namespace))
+		rec = binary.AppendUvarint(rec, tagNamespace)
+		rec = appendString(rec, string(ns))
+	}
+	rec = appendStrings(rec, tagCategories, a.Categories)
+	if a.Disambiguation {
+		rec = binary.AppendUvarint(rec, tagDisambiguation)
+		rec = binary.AppendUvarint(rec, 0)
+	}
+	if a.RevisionID != 0 || !a.Timestamp.IsZero() || a.Size != 0 {
+		var ts int64
+		if !a.Timestamp.IsZero() {
+			ts = a.Timestamp.Unix()
+		}
+		field := binary.AppendUvarint(nil, uint64(a.RevisionID))
+		field = binary.AppendVarint(field, ts)
+		field = binary.AppendUvarint(field, uint64(a.Size))
+		rec = binary.AppendUvarint(rec, tagRevision)
+		rec = appendString(rec, string(field))
+	}
+	if a.Description != "" {
+		rec = binary.AppendUvarint(rec, tagDescription)
+		rec = appendString(rec, a.Description)
+	}
+	rec = appendStrings(rec, tagFragments, a.Fragments)
+	rec = appendStrings(rec, tagSections, a.Sections)
+	if len(a.Interwiki) > 0 {
+		pairs := make([]string, 0, 2*len(a.Interwiki))
+		for _, iw := range a.Interwiki {
+			pairs = append(pairs, iw.Prefix, iw.Target)
+		}
+		rec = appendStrings(rec, tagInterwiki, pairs)
+	}
+	rec = binary.AppendUvarint(rec, tagEnd)
+	ce.scratch = rec
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	ce.payload.Write(lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(rec)))])
+	ce.payload.Write(rec)
+	ce.records++
+
+	if ce.records >= blockRecords || ce.payload.Len() >= blockBytes {
+		return ce.flush()
+	}
+	return nil
+}
+
+// flush writes out the pending block, if there is one.
+func (ce *compactEncoder) flush() error {
+	if ce.records == 0 {
+		return nil
+	}
+
+	block := binary.AppendUvarint(nil, uint64(ce.records))
+	block = binary.AppendUvarint(block, uint64(len(ce.dictList)))
+	for _, s := range ce.dictList {
+		block = appendString(block, s)
+	}
+	block = binary.AppendUvarint(block, uint64(ce.payload.Len()))
+	block = append(block, ce.payload.Bytes()...)
+	block = binary.LittleEndian.AppendUint32(block, crc32.ChecksumIEEE(block))
+
+	if _, err := ce.w.Write(block); err != nil {
+		return err
+	}
+
+	ce.total += uint64(ce.records)
+	ce.blocks++
+	ce.records = 0
+	ce.payload.Reset()
+	ce.dictList = ce.dictList[:0]
+	ce.dict = make(map[string]int)
+	return nil
+}
+
+func (ce *compactEncoder) close() error {
+	if err := ce.flush(); err != nil {
+		return err
+	}
+
+	footer := binary.AppendUvarint(nil, 0)
+	footer = binary.AppendUvarint(footer, ce.total)
+	footer = binary.AppendUvarint(footer, ce.blocks)
+	footer = binary.LittleEndian.AppendUint32(footer, crc32.ChecksumIEEE(footer))
+	_, err := ce.w.Write(footer)
+	return err
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendStrings appends a record extension holding a list of strings, if
+// there are any.
+func appendStrings(buf []byte, tag uint64, list []string) []byte {
+	if len(list) == 0 {
+		return buf
+	}
+	var field []byte
+	for _, s := range list {
+		field = appendString(field, s)
+	}
+	buf = binary.AppendUvarint(buf, tag)
+	return appendString(buf, string(field))
+}
+
+// compactBlock is a block read from a compact stream, before its records
+// are decoded.
+type compactBlock struct {
+	Offset  int64    // Offset of the block in the decompressed stream.
+	Dict    []string // Link target dictionary.
+	Records [][]byte // Raw records.
+	Offsets []int64  // Offset of each record in the decompressed stream.
+	Valid   bool     // If the block's checksum matched.
+}
+
+// compactFooter is the trailer of a compact stream.
+type compactFooter struct {
+	Offset  int64
+	Records uint64
+	Blocks  uint64
+	Valid   bool // If the footer's checksum matched.
+}
+
+// compactDecoder reads `StrippedArticle`s in the compact format.
+type compactDecoder struct {
+	r      *bufio.Reader
+	offset int64 // Bytes consumed from r.
+	crc    uint32
+
+	block   *compactBlock
+	next    int // Next record in block.
+	records uint64
+	blocks  uint64
+	done    bool
+}
+
+// newCompactDecoder creates a compactDecoder, consuming the header from `r`.
+func newCompactDecoder(r *bufio.Reader) (*compactDecoder, error) {
+	cd := &compactDecoder{r: r}
+	header := make([]byte, len(compactMagic)+1)
+	if err := cd.readFull(header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(compactMagic)]) != compactMagic {
+		return nil, ErrCorrupt
+	}
+	if header[len(compactMagic)] != compactVersion {
+		return nil, fmt.Errorf("unsupported .wpindex version %d", header[len(compactMagic)])
+	}
+	return cd, nil
+}
+
+func (cd *compactDecoder) decode() (*StrippedArticle, error) {
+	for cd.block == nil || cd.next >= len(cd.block.Records) {
+		if cd.done {
+			return nil, EOF
+		}
+		block, footer, err := cd.readBlock()
+		if err != nil {
+			return nil, err
+		}
+		if footer != nil {
+			if !footer.Valid {
+				return nil, fmt.Errorf("%v at offset %d", ErrChecksum, footer.Offset)
+			}
+			if footer.Records != cd.records || footer.Blocks != cd.blocks {
+				return nil, fmt.Errorf("%v: footer expects %d records in %d blocks, read %d in %d",
+					ErrCorrupt, footer.Records, footer.Blocks, cd.records, cd.blocks)
+			}
+			cd.done = true
+			return nil, EOF
+		}
+		if !block.Valid {
+			return nil, fmt.Errorf("%v at offset %d", ErrChecksum, block.Offset)
+		}
+		cd.block = block
+		cd.next = 0
+	}
+
+	i := cd.next
+	cd.next++
+	a, err := decodeCompactRecord(cd.block.Records[i], cd.block.Dict)
+	if err != nil {
+		return nil, fmt.Errorf("%v at offset %d", err, cd.block.Offsets[i])
+	}
+	return a, nil
+}
+
+// readBlock reads the next block or the footer from the stream. Exactly one
+// of the returned block and footer is non-nil when err is nil. A checksum
+// mismatch isn't an error here, it only clears the Valid flag.
+func (cd *compactDecoder) readBlock() (*compactBlock, *compactFooter, error) {
+	start := cd.offset
+	cd.crc = 0
+
+	n, err := cd.readUvarint()
+	if err == io.EOF {
+		return nil, nil, io.ErrUnexpectedEOF // no footer: truncated file
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	if n == 0 {
+		footer := &compactFooter{Offset: start}
+		if footer.Records, err = cd.readUvarint(); err != nil {
+			return nil, nil, noEOF(err)
+		}
+		if footer.Blocks, err = cd.readUvarint(); err != nil {
+			return nil, nil, noEOF(err)
+		}
+		if footer.Valid, err = cd.checkCRC(); err != nil {
+			return nil, nil, err
+		}
+		return nil, footer, nil
+	}
+
+	if n > uint64(maxBlockBytes) {
+		return nil, nil, fmt.Errorf("%v: bad record count at offset %d", ErrCorrupt, start)
+	}
+
+	block := &compactBlock{Offset: start}
+
+	nDict, err := cd.readUvarint()
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	if nDict > uint64(maxBlockBytes) {
+		return nil, nil, fmt.Errorf("%v: bad dictionary size at offset %d", ErrCorrupt, start)
+	}
+	block.Dict = make([]string, 0, minInt(int(nDict), 4096))
+	for i := uint64(0); i < nDict; i++ {
+		s, err := cd.readString()
+		if err != nil {
+			return nil, nil, noEOF(err)
+		}
+		block.Dict = append(block.Dict, s)
+	}
+
+	payloadLen, err := cd.readUvarint()
+	if err != nil {
+		return nil, nil, noEOF(err)
+	}
+	if payloadLen > uint64(maxBlockBytes) {
+		return nil, nil, fmt.Errorf("%v: bad payload length at offset %d", ErrCorrupt, start)
+	}
+	payloadStart := cd.offset
+	payload := make([]byte, payloadLen)
+	if err := cd.readFull(payload); err != nil {
+		return nil, nil, noEOF(err)
+	}
+	if block.Valid, err = cd.checkCRC(); err != nil {
+		return nil, nil, err
+	}
+
+	// Split the payload into records.
+	pos := 0
+	for pos < len(payload) {
+		recLen, k := binary.Uvarint(payload[pos:])
+		if k <= 0 || recLen > uint64(len(payload)-pos-k) {
+			return nil, nil, fmt.Errorf("%v: bad record at offset %d", ErrCorrupt, payloadStart+int64(pos))
+		}
+		block.Offsets = append(block.Offsets, payloadStart+int64(pos))
+		block.Records = append(block.Records, payload[pos+k:pos+k+int(recLen)])
+		pos += k + int(recLen)
+	}
+	if uint64(len(block.Records)) != n {
+		return nil, nil, fmt.Errorf("%v: block at offset %d has %d records, expected %d", ErrCorrupt, start, len(block.Records), n)
+	}
+
+	cd.records += n
+	cd.blocks++
+	return block, nil, nil
+}
+
+// checkCRC reads a checksum and compares it against everything read since
+// the last reset.
+func (cd *compactDecoder) checkCRC() (bool, error) {
+	want := cd.crc
+	var sum [4]byte
+	if _, err := io.ReadFull(cd.r, sum[:]); err != nil {
+		return false, noEOF(err)
+	}
+	cd.offset += 4
+	return binary.LittleEndian.Uint32(sum[:]) == want, nil
+}
+
+func (cd *compactDecoder) readFull(buf []byte) error {
+	n, err := io.ReadFull(cd.r, buf)
+	cd.offset += int64(n)
+	cd.crc = crc32.Update(cd.crc, crc32.IEEETable, buf[:n])
+	return err
+}
+
+func (cd *compactDecoder) readUvarint() (uint64, error) {
+	var x uint64
+	var s uint
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := cd.r.ReadByte()
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		cd.offset++
+		cd.crc = crc32.Update(cd.crc, crc32.IEEETable, []byte{b})
+		if b < 0x80 {
+			return x | uint64(b)<<s, nil
+		}
+		x |= uint64(b&0x7f) << s
+		s += 7
+	}
+	return 0, ErrCorrupt
+}
+
+func (cd *compactDecoder) readString() (string, error) {
+	n, err := cd.readUvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(maxBlockBytes) {
+		return "", ErrCorrupt
+	}
+	buf := make([]byte, n)
+	if err := cd.readFull(buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// noEOF turns a plain EOF in the middle of a block into ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// recordReader decodes the fields of a single compact record.
+type recordReader struct {
+	buf []byte
+	err error
+}
+
+func (rr *recordReader) uvarint() uint64 {
+	if rr.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(rr.buf)
+	if n <= 0 {
+		rr.err = ErrCorrupt
+		return 0
+	}
+	rr.buf = rr.buf[n:]
+	return x
+}
+
+func (rr *recordReader) varint() int64 {
+	if rr.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(rr.buf)
+	if n <= 0 {
+		rr.err = ErrCorrupt
+		return 0
+	}
+	rr.buf = rr.buf[n:]
+	return x
+}
+
+func (rr *recordReader) bytes() []byte {
+	n := rr.uvarint()
+	if rr.err != nil {
+		return nil
+	}
+	if n > uint64(len(rr.buf)) {
+		rr.err = ErrCorrupt
+		return nil
+	}
+	b := rr.buf[:n]
+	rr.buf = rr.buf[n:]
+	return b
+}
+
+func (rr *recordReader) string() string {
+	return string(rr.bytes())
+}
+
+// decodeCompactRecord decodes one record against its block's dictionary.
+func decodeCompactRecord(rec []byte, dict []string) (*StrippedArticle, error) {
+	rr := &recordReader{buf: rec}
+	a := &StrippedArticle{}
+	a.Title = rr.string()
+	a.ID = int(rr.varint())
+	a.Redirect = rr.string()
+
+	nLinks := rr.uvarint()
+	if nLinks > uint64(len(rr.buf)) {
+		return nil, ErrCorrupt // every link takes at least one byte
+	}
+	if nLinks > 0 {
+		a.Links = make([]string, nLinks)
+	}
+	for i := range a.Links {
+		d := rr.uvarint()
+		if rr.err == nil && d >= uint64(len(dict)) {
+			rr.err = ErrCorrupt
+		}
+		if rr.err != nil {
+			return nil, rr.err
+		}
+		a.Links[i] = dict[d]
+	}
+
+	for rr.err == nil {
+		tag := rr.uvarint()
+		if tag == tagEnd {
+			break
+		}
+		field := rr.bytes()
+		if (tag == tagEdges || tag == tagFlags) && rr.err == nil && len(field) != len(a.Links) {
+			return nil, ErrCorrupt
+		}
+		switch tag {
+		case tagEdges:
+			a.Edges = make([]EdgeType, len(field))
+			for i, e := range field {
+				a.Edges[i] = EdgeType(e)
+			}
+		case tagFlags:
+			a.Flags = make([]LinkFlag, len(field))
+			for i, f := range field {
+				a.Flags[i] = LinkFlag(f)
+			}
+		case tagAnchors:
+			if a.Anchors = readStrings(field, len(a.Links)); a.Anchors == nil {
+				return nil, ErrCorrupt
+			}
+		case tagContexts:
+			if a.Contexts = readStrings(field, len(a.Links)); a.Contexts == nil {
+				return nil, ErrCorrupt
+			}
+		case tagNamespace:
+			ns, n := binary.Varint(field)
+			if n <= 0 || n != len(field) {
+				return nil, ErrCorrupt
+			}
+			a.Namespace = int(ns)
+		case tagCategories:
+			if a.Categories = readStrings(field, -1); a.Categories == nil {
+				return nil, ErrCorrupt
+			}
+		case tagDisambiguation:
+			a.Disambiguation = true
+		case tagRevision:
+			fr := &recordReader{buf: field}
+			a.RevisionID = int(fr.uvarint())
+			if ts := fr.varint(); ts != 0 {
+				a.Timestamp = time.Unix(ts, 0).UTC()
+			}
+			a.Size = int(fr.uvarint())
+			if fr.err != nil || len(fr.buf) != 0 {
+				return nil, ErrCorrupt
+			}
+		case tagDescription:
+			a.Description = string(field)
+		case tagFragments:
+			if a.Fragments = readStrings(field, len(a.Links)); a.Fragments == nil {
+				return nil, ErrCorrupt
+			}
+		case tagSections:
+			if a.Sections = readStrings(field, len(a.Links)); a.Sections == nil {
+				return nil, ErrCorrupt
+			}
+		case tagInterwiki:
+			pairs := readStrings(field, -1)
+			if pairs == nil || len(pairs)%2 != 0 {
+				return nil, ErrCorrupt
+			}
+			for i := 0; i < len(pairs); i += 2 {
+				a.Interwiki = append(a.Interwiki, InterwikiLink{Prefix: pairs[i], Target: pairs[i+1]})
+			}
+		}
+		// Other tags are extensions this version doesn't know; skip them.
+	}
+
+	if rr.err == nil && len(rr.buf) != 0 {
+		rr.err = ErrCorrupt
+	}
+	if rr.err != nil {
+		return nil, rr.err
+	}
+	return a, nil
+}
+
+// readStrings reads a record extension holding `n` strings, or any number
+// of them if `n` is -1, returning nil if it doesn't.
+func readStrings(field []byte, n int) []string {
+	rr := &recordReader{buf: field}
+	size := n
+	if n < 0 {
+		size = len(field) // every string takes at least one byte
+	}
+	list := make([]string, 0, minInt(size, len(field)))
+	for len(rr.buf) > 0 && rr.err == nil {
+		list = append(list, rr.string())
+	}
+	if rr.err != nil || n >= 0 && len(list) != n {
+		return nil
+	}
+	return list
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
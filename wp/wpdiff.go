@@ -0,0 +1,492 @@
+package wikipath
+
+import (
+	"errors"
+	"hash/fnv"
+	"io"
+	"sort"
+)
+
+// DiffKind is the kind of change to an article between two *.wpindex files.
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"   // The article is only in the new file.
+	DiffRemoved DiffKind = "removed" // The article is only in the old file.
+	DiffChanged DiffKind = "changed" // The article is in both, but differs.
+
+	// The article's ID (or title) is repeated within one file.
+	DiffDuplicate DiffKind = "duplicate"
+)
+
+// ArticleDiff is the change to one article between two *.wpindex files.
+// Articles are matched by page ID, so a renamed article is a change rather
+// than a removal and an addition. Articles without an ID are matched by title.
+type ArticleDiff struct {
+	Kind  DiffKind `json:"kind"`
+	ID    int      `json:"id"`
+	Title string   `json:"title"` // Title in the new file, or the old one if removed.
+
+	OldTitle string `json:"old_title,omitempty"` // Set if the article was renamed.
+
+	RedirectChanged bool   `json:"redirect_changed,omitempty"`
+	Redirect        string `json:"redirect,omitempty"`
+	OldRedirect     string `json:"old_redirect,omitempty"`
+
+	AddedLinks   []string `json:"added_links,omitempty"`
+	RemovedLinks []string `json:"removed_links,omitempty"`
+
+	File string `json:"file,omitempty"` // For duplicates, "old" or "new".
+}
+
+// Renamed returns true if the article's title changed.
+func (ad *ArticleDiff) Renamed() bool {
+	return ad.OldTitle != ""
+}
+
+// DiffSummary counts the changes between two *.wpindex files.
+type DiffSummary struct {
+	Old          int `json:"old"` // Articles in the old file.
+	New          int `json:"new"` // Articles in the new file.
+	Added        int `json:"added"`
+	Removed      int `json:"removed"`
+	Renamed      int `json:"renamed"`
+	Redirects    int `json:"redirects"` // Articles whose redirect target changed.
+	LinksChanged int `json:"links_changed"`
+	LinksAdded   int `json:"links_added"`
+	LinksRemoved int `json:"links_removed"`
+	Duplicates   int `json:"duplicates"` // Repeated IDs or titles, in either file.
+}
+
+func (ds *DiffSummary) add(ad *ArticleDiff) {
+	switch ad.Kind {
+	case DiffAdded:
+		ds.Added++
+	case DiffRemoved:
+		ds.Removed++
+	case DiffDuplicate:
+		ds.Duplicates++
+	}
+	if ad.Renamed() {
+		ds.Renamed++
+	}
+	if ad.Kind == DiffChanged && ad.RedirectChanged {
+		ds.Redirects++
+	}
+	if ad.Kind == DiffChanged && (len(ad.AddedLinks) > 0 || len(ad.RemovedLinks) > 0) {
+		ds.LinksChanged++
+	}
+	ds.LinksAdded += len(ad.AddedLinks)
+	ds.LinksRemoved += len(ad.RemovedLinks)
+}
+
+// diffKey identifies an article across two *.wpindex files.
+type diffKey struct {
+	id    int
+	title string // Normalized title, only if there's no ID.
+}
+
+func newDiffKey(a *StrippedArticle) diffKey {
+	if a.ID != 0 {
+		return diffKey{id: a.ID}
+	}
+	return diffKey{title: NormalizeArticleTitle(a.Title)}
+}
+
+func (k diffKey) less(o diffKey) bool {
+	if k.id != o.id {
+		return k.id < o.id
+	}
+	return k.title < o.title
+}
+
+// errUnordered is returned by the streaming diff when a file isn't sorted
+// by page ID.
+var errUnordered = errors.New("wpindex is not sorted by page ID")
+
+// diffSide is one of the two files being streamed.
+type diffSide struct {
+	file    string // "old" or "new", for reporting duplicates.
+	reader  *WpindexReader
+	pending map[diffKey]*StrippedArticle // Articles not yet matched in the other file.
+	titles  map[string]bool              // Keys of articles without an ID.
+	dups    []*ArticleDiff
+	lastID  int
+	done    bool
+	read    int
+}
+
+func newDiffSide(file string, reader *WpindexReader) *diffSide {
+	return &diffSide{
+		file:    file,
+		reader:  reader,
+		pending: make(map[diffKey]*StrippedArticle),
+		titles:  make(map[string]bool),
+	}
+}
+
+// next reads the next article, returning nil at the end of the file or if
+// the article is a duplicate.
+func (ds *diffSide) next() (*StrippedArticle, error) {
+	a, err := ds.reader.ReadArticle()
+	if err == EOF {
+		ds.done = true
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ds.read++
+
+	if a.ID == 0 {
+		k := NormalizeArticleTitle(a.Title)
+		if ds.titles[k] {
+			ds.dups = append(ds.dups, duplicateDiff(a, ds.file))
+			return nil, nil
+		}
+		ds.titles[k] = true
+		return a, nil
+	}
+
+	switch {
+	case a.ID < ds.lastID:
+		return nil, errUnordered
+	case a.ID == ds.lastID:
+		ds.dups = append(ds.dups, duplicateDiff(a, ds.file))
+		return nil, nil
+	}
+	ds.lastID = a.ID
+	return a, nil
+}
+
+// wpindexDiffer passes changes to the visitor, counting them.
+type wpindexDiffer struct {
+	visitor func(*ArticleDiff) bool
+	summary *DiffSummary
+	emitted map[diffKey]bool // Changed articles already passed to the visitor.
+}
+
+func (d *wpindexDiffer) emit(ad *ArticleDiff) error {
+	if ad == nil {
+		return nil
+	}
+	d.summary.add(ad)
+	if !d.visitor(ad) {
+		return ErrStopped
+	}
+	return nil
+}
+
+// emitChanged emits the change to an article in both files, unless it was
+// already emitted before falling back to the unsorted diff.
+func (d *wpindexDiffer) emitChanged(k diffKey, ad *ArticleDiff) error {
+	if ad == nil || d.emitted[k] {
+		return nil
+	}
+	d.emitted[k] = true
+	return d.emit(ad)
+}
+
+// DiffWpindex compares two *.wpindex files, calling `visitor` for each
+// article which was added, removed, changed or duplicated, and stopping if
+// it returns false. `openOld` and `openNew` open a fresh reader for each
+// file, and may be called more than once.
+//
+// Files sorted by page ID (like two dumps of the same wiki) are streamed
+// side by side in very little memory. As soon as either file turns out to
+// be out of order, which is the default for `wikipath index`, the diff
+// starts again in three passes: the old file is read into a map of
+// 64-bit hashes of each article, the new file is compared against it, and
+// the old file is read once more for the details of what changed. That
+// holds a hash for every article in the old file, plus the new version of
+// every changed article.
+//
+// An ID (or title, without an ID) which shows up more than once in a file
+// is reported as a duplicate, and only its first article is compared.
+func DiffWpindex(openOld func() (*WpindexReader, error), openNew func() (*WpindexReader, error), visitor func(*ArticleDiff) bool) (*DiffSummary, error) {
+	d := &wpindexDiffer{
+		visitor: visitor,
+		summary: &DiffSummary{},
+		emitted: make(map[diffKey]bool),
+	}
+
+	err := d.diffSorted(openOld, openNew)
+	if err == errUnordered {
+		err = d.diffUnsorted(openOld, openNew)
+	}
+	return d.summary, err
+}
+
+// diffSorted streams both files, reading from whichever is behind by page
+// ID. Unmatched articles are held until their counterpart turns up. Only
+// changes to articles in both files are emitted before the end, so the
+// unsorted diff can pick up if this returns `errUnordered`.
+func (d *wpindexDiffer) diffSorted(openOld func() (*WpindexReader, error), openNew func() (*WpindexReader, error)) error {
+	oldReader, err := openOld()
+	if err != nil {
+		return err
+	}
+	defer oldReader.Close()
+	newReader, err := openNew()
+	if err != nil {
+		return err
+	}
+	defer newReader.Close()
+
+	before := newDiffSide("old", oldReader)
+	after := newDiffSide("new", newReader)
+
+	for !before.done || !after.done {
+		side, other := before, after
+		if before.done || (!after.done && after.lastID < before.lastID) {
+			side, other = after, before
+		}
+
+		a, err := side.next()
+		if err != nil {
+			return err
+		} else if a == nil {
+			continue
+		}
+
+		k := newDiffKey(a)
+		match, ok := other.pending[k]
+		if !ok {
+			side.pending[k] = a
+			continue
+		}
+		delete(other.pending, k)
+
+		var ad *ArticleDiff
+		if side == before {
+			ad = diffArticles(a, match)
+		} else {
+			ad = diffArticles(match, a)
+		}
+		if err := d.emitChanged(k, ad); err != nil {
+			return err
+		}
+	}
+
+	// Whatever's left over is only in one of the files.
+	for _, kind := range []DiffKind{DiffRemoved, DiffAdded} {
+		side := before
+		if kind == DiffAdded {
+			side = after
+		}
+
+		keys := make([]diffKey, 0, len(side.pending))
+		for k := range side.pending {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+		for _, k := range keys {
+			if err := d.emit(onlyDiff(kind, side.pending[k])); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, side := range []*diffSide{before, after} {
+		for _, ad := range side.dups {
+			if err := d.emit(ad); err != nil {
+				return err
+			}
+		}
+	}
+
+	d.summary.Old = before.read
+	d.summary.New = after.read
+	return nil
+}
+
+// diffState is how far along an article is in the unsorted diff.
+type diffState int
+
+const (
+	diffInOld   diffState = iota // Seen in the old file only, so far.
+	diffSame                     // Seen in both, and the same.
+	diffChanged                  // Seen in both, and different.
+	diffInNew                    // Only in the new file.
+	diffDone                     // Already emitted.
+)
+
+type diffEntry struct {
+	digest uint64
+	state  diffState
+}
+
+// diffUnsorted diffs two files in any order, in three passes.
+func (d *wpindexDiffer) diffUnsorted(openOld func() (*WpindexReader, error), openNew func() (*WpindexReader, error)) error {
+	entries := make(map[diffKey]diffEntry)
+	changed := make(map[diffKey]*StrippedArticle) // New versions of changed articles.
+
+	err := eachWpindexArticle(openOld, func(a *StrippedArticle) error {
+		d.summary.Old++
+		k := newDiffKey(a)
+		if _, ok := entries[k]; ok {
+			return d.emit(duplicateDiff(a, "old"))
+		}
+		entries[k] = diffEntry{digest: articleDigest(a), state: diffInOld}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = eachWpindexArticle(openNew, func(a *StrippedArticle) error {
+		d.summary.New++
+		k := newDiffKey(a)
+		e, ok := entries[k]
+		switch {
+		case !ok:
+			entries[k] = diffEntry{state: diffInNew}
+			return d.emit(onlyDiff(DiffAdded, a))
+		case e.state != diffInOld:
+			return d.emit(duplicateDiff(a, "new"))
+		case e.digest == articleDigest(a):
+			entries[k] = diffEntry{state: diffSame}
+		default:
+			entries[k] = diffEntry{state: diffChanged}
+			changed[k] = a
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return eachWpindexArticle(openOld, func(a *StrippedArticle) error {
+		k := newDiffKey(a)
+		e := entries[k]
+		entries[k] = diffEntry{state: diffDone}
+		switch e.state {
+		case diffInOld:
+			return d.emit(onlyDiff(DiffRemoved, a))
+		case diffChanged:
+			after := changed[k]
+			delete(changed, k)
+			return d.emitChanged(k, diffArticles(a, after))
+		}
+		return nil
+	})
+}
+
+// eachWpindexArticle opens a *.wpindex file and calls `fn` for each article.
+func eachWpindexArticle(open func() (*WpindexReader, error), fn func(*StrippedArticle) error) error {
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		a, err := reader.ReadArticle()
+		if err == EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+}
+
+// articleDigest hashes everything `diffArticles` compares, so two versions
+// of an article with the same digest are (almost certainly) the same.
+func articleDigest(a *StrippedArticle) uint64 {
+	links := make([]string, 0, len(a.Links))
+	for l := range linkSet(a.Links) {
+		links = append(links, l)
+	}
+	sort.Strings(links)
+
+	h := fnv.New64a()
+	io.WriteString(h, a.Title)
+	h.Write([]byte{0})
+	io.WriteString(h, NormalizeArticleTitle(a.Redirect))
+	for _, l := range links {
+		h.Write([]byte{0})
+		io.WriteString(h, l)
+	}
+	return h.Sum64()
+}
+
+// onlyDiff returns the diff for an article in only one of the files.
+func onlyDiff(kind DiffKind, a *StrippedArticle) *ArticleDiff {
+	ad := &ArticleDiff{Kind: kind, ID: a.ID, Title: a.Title, Redirect: a.Redirect}
+	if kind == DiffAdded {
+		ad.AddedLinks = uniqueLinks(a.Links)
+	} else {
+		ad.RemovedLinks = uniqueLinks(a.Links)
+	}
+	return ad
+}
+
+// duplicateDiff returns the diff for a repeated ID or title in `file`.
+func duplicateDiff(a *StrippedArticle, file string) *ArticleDiff {
+	return &ArticleDiff{Kind: DiffDuplicate, ID: a.ID, Title: a.Title, File: file}
+}
+
+// diffArticles compares two versions of an article, returning nil if
+// they're the same.
+func diffArticles(before *StrippedArticle, after *StrippedArticle) *ArticleDiff {
+	ad := &ArticleDiff{Kind: DiffChanged, ID: after.ID, Title: after.Title, Redirect: after.Redirect}
+	changed := false
+
+	if before.Title != after.Title {
+		ad.OldTitle = before.Title
+		changed = true
+	}
+
+	if NormalizeArticleTitle(before.Redirect) != NormalizeArticleTitle(after.Redirect) {
+		ad.RedirectChanged = true
+		ad.OldRedirect = before.Redirect
+		changed = true
+	}
+
+	oldLinks := linkSet(before.Links)
+	newLinks := linkSet(after.Links)
+	for _, l := range uniqueLinks(after.Links) {
+		if !oldLinks[NormalizeArticleTitle(l)] {
+			ad.AddedLinks = append(ad.AddedLinks, l)
+		}
+	}
+	for _, l := range uniqueLinks(before.Links) {
+		if !newLinks[NormalizeArticleTitle(l)] {
+			ad.RemovedLinks = append(ad.RemovedLinks, l)
+		}
+	}
+	if len(ad.AddedLinks) > 0 || len(ad.RemovedLinks) > 0 {
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return ad
+}
+
+// linkSet returns the set of normalized link targets.
+func linkSet(links []string) map[string]bool {
+	set := make(map[string]bool, len(links))
+	for _, l := range links {
+		set[NormalizeArticleTitle(l)] = true
+	}
+	return set
+}
+
+// uniqueLinks removes repeated links, keeping the first of each.
+func uniqueLinks(links []string) []string {
+	seen := make(map[string]bool, len(links))
+	var unique []string
+	for _, l := range links {
+		k := NormalizeArticleTitle(l)
+		if !seen[k] {
+			seen[k] = true
+			unique = append(unique, l)
+		}
+	}
+	return unique
+}
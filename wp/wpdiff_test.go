@@ -0,0 +1,134 @@
+package wikipath
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func diffTestOpener(data []byte) func() (*WpindexReader, error) {
+	return func() (*WpindexReader, error) {
+		return NewWpindexReader(bytes.NewReader(data))
+	}
+}
+
+func diffTestWpindex(t *testing.T, before []*StrippedArticle, after []*StrippedArticle) (map[int]*ArticleDiff, []*ArticleDiff, *DiffSummary) {
+	openOld := diffTestOpener(writeWpindex(t, FormatCompact, before))
+	openNew := diffTestOpener(writeWpindex(t, FormatGob, after))
+
+	diffs := make(map[int]*ArticleDiff)
+	var dups []*ArticleDiff
+	summary, err := DiffWpindex(openOld, openNew, func(ad *ArticleDiff) bool {
+		if ad.Kind == DiffDuplicate {
+			dups = append(dups, ad)
+			return true
+		}
+		if diffs[ad.ID] != nil {
+			t.Fatalf("article %d diffed twice", ad.ID)
+		}
+		diffs[ad.ID] = ad
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return diffs, dups, summary
+}
+
+func TestDiffWpindex(t *testing.T) {
+	before := []*StrippedArticle{
+		{Title: "A", ID: 1, Links: []string{"B", "C"}},
+		{Title: "B", ID: 2, Links: []string{"A"}},
+		{Title: "C", ID: 3},
+		{Title: "Old name", ID: 4, Links: []string{"A"}},
+		{Title: "R", ID: 5, Redirect: "A"},
+		{Title: "Gone", ID: 6},
+	}
+	after := map[int]*StrippedArticle{
+		1: {Title: "A", ID: 1, Links: []string{"B", "b", "D"}},
+		2: {Title: "B", ID: 2, Links: []string{"A"}},
+		3: {Title: "C", ID: 3},
+		4: {Title: "New name", ID: 4, Links: []string{"A"}},
+		5: {Title: "R", ID: 5, Redirect: "B"},
+		7: {Title: "New", ID: 7, Links: []string{"A"}},
+	}
+
+	// Sorted, unsorted, and only out of order after some changes are found.
+	for _, order := range [][]int{
+		{1, 2, 3, 4, 5, 7},
+		{7, 5, 1, 2, 3, 4},
+		{1, 2, 4, 5, 7, 3},
+	} {
+		var articles []*StrippedArticle
+		for _, id := range order {
+			articles = append(articles, after[id])
+		}
+		t.Run(fmt.Sprint(order), func(t *testing.T) {
+			testDiffWpindex(t, before, articles)
+		})
+	}
+}
+
+func testDiffWpindex(t *testing.T, before []*StrippedArticle, after []*StrippedArticle) {
+	diffs, dups, summary := diffTestWpindex(t, before, after)
+	assertEqual(t, len(diffs), 5)
+	assertEqual(t, len(dups), 0)
+
+	want := map[int]*ArticleDiff{
+		1: {Kind: DiffChanged, ID: 1, Title: "A", AddedLinks: []string{"D"}, RemovedLinks: []string{"C"}},
+		4: {Kind: DiffChanged, ID: 4, Title: "New name", OldTitle: "Old name"},
+		5: {Kind: DiffChanged, ID: 5, Title: "R", Redirect: "B", OldRedirect: "A", RedirectChanged: true},
+		6: {Kind: DiffRemoved, ID: 6, Title: "Gone"},
+		7: {Kind: DiffAdded, ID: 7, Title: "New", AddedLinks: []string{"A"}},
+	}
+	for id, ad := range want {
+		if !reflect.DeepEqual(diffs[id], ad) {
+			t.Errorf("article %d: got %+v, want %+v", id, diffs[id], ad)
+		}
+	}
+
+	assertEqual(t, *summary, DiffSummary{
+		Old: 6, New: 6,
+		Added: 1, Removed: 1, Renamed: 1, Redirects: 1, LinksChanged: 1,
+		LinksAdded: 2, LinksRemoved: 1,
+	})
+}
+
+func TestDiffWpindexSame(t *testing.T) {
+	diffs, dups, _ := diffTestWpindex(t, testArticles, testArticles)
+	assertEqual(t, len(diffs), 0)
+	assertEqual(t, len(dups), 0)
+}
+
+func TestDiffWpindexDuplicates(t *testing.T) {
+	before := []*StrippedArticle{
+		{Title: "A", ID: 1},
+		{Title: "B", ID: 2},
+		{Title: "B again", ID: 2},
+		{Title: "C", ID: 3},
+	}
+	sorted := []*StrippedArticle{
+		{Title: "A", ID: 1},
+		{Title: "B", ID: 2},
+		{Title: "C", ID: 3},
+		{Title: "C again", ID: 3},
+	}
+	unsorted := []*StrippedArticle{sorted[2], sorted[0], sorted[3], sorted[1]}
+
+	for name, after := range map[string][]*StrippedArticle{"sorted": sorted, "unsorted": unsorted} {
+		t.Run(name, func(t *testing.T) {
+			diffs, dups, summary := diffTestWpindex(t, before, after)
+			assertEqual(t, len(diffs), 0)
+			assertEqual(t, len(dups), 2)
+			want := []*ArticleDiff{
+				{Kind: DiffDuplicate, ID: 2, Title: "B again", File: "old"},
+				{Kind: DiffDuplicate, ID: 3, Title: "C again", File: "new"},
+			}
+			if !reflect.DeepEqual(dups, want) {
+				t.Errorf("got duplicates %+v, want %+v", dups, want)
+			}
+			assertEqual(t, *summary, DiffSummary{Old: 4, New: 4, Duplicates: 2})
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package wikipath
 
 import (
+	"bufio"
 	"compress/gzip"
 	"encoding/gob"
+	"fmt"
 	"io"
+	"time"
 )
 
 const compressionLevel = gzip.BestSpeed
@@ -11,83 +14,450 @@ const compressionLevel = gzip.BestSpeed
 // EOF is the error returned when the `*.wpindex` file ends.
 var EOF = io.EOF
 
+// WpindexFormat is the encoding used for articles inside a *.wpindex file.
+type WpindexFormat int
+
+const (
+	// FormatGob encodes each article with `encoding/gob`.
+	FormatGob WpindexFormat = iota
+
+	// FormatCompact encodes articles in checksummed blocks, with a
+	// dictionary of link targets per block.
+	FormatCompact
+)
+
+// ParseWpindexFormat parses a format name, as used on the command line.
+func ParseWpindexFormat(name string) (WpindexFormat, error) {
+	switch name {
+	case "gob":
+		return FormatGob, nil
+	case "compact":
+		return FormatCompact, nil
+	default:
+		return 0, fmt.Errorf("unknown .wpindex format '%s'", name)
+	}
+}
+
+func (f WpindexFormat) String() string {
+	switch f {
+	case FormatGob:
+		return "gob"
+	case FormatCompact:
+		return "compact"
+	default:
+		return fmt.Sprintf("WpindexFormat(%d)", int(f))
+	}
+}
+
 // StrippedArticle is an article, stripped of everything save for its
 // title, id, redirect title, and string links.
 type StrippedArticle struct {
-	Title    string
-	ID       int
-	Redirect string
-	Links    []string
+	Title     string
+	ID        int
+	Redirect  string
+	Namespace int        // Namespace the page is in, 0 for articles.
+	Links     []string   // Titles linked to, in the order they're in the article.
+	Edges     []EdgeType // Type of each link in Links, or nil if they're all EdgeProse.
+	Flags     []LinkFlag // Flags of each link in Links, or nil if none have any.
+	Anchors   []string   // Text of each link in Links, "" if it's the title. Nil if unknown.
+	Contexts  []string   // Sentence each link in Links is in. Nil if unknown.
+	Fragments []string   // Section each link in Links goes to, after the '#'. Nil if none do.
+	Sections  []string   // Heading of the section each link in Links is in, "" in the lead. Nil if all are.
+
+	Categories []string        // Titles of the categories the page is in.
+	Interwiki  []InterwikiLink // Links to pages on other wikis, like [[fr:Paris]].
+
+	Disambiguation bool // If the page is a disambiguation page.
+
+	RevisionID  int       // ID of the revision the page is from, or 0 if unknown.
+	Timestamp   time.Time // When the revision was made, or zero if unknown.
+	Size        int       // Length of the page's wikitext in bytes, or 0 if unknown.
+	Description string    // The page's short description, from {{Short description}}.
+}
+
+// InterwikiLink is a link to a page on another wiki or language edition.
+type InterwikiLink struct {
+	Prefix string // Lowercase prefix of the wiki, like "fr" or "wikt".
+	Target string // Title of the page on that wiki.
 }
 
-// NewStrippedArticle creates a StrippedArticle from an Article.
+// String gets the link as it would be written, like "fr:Paris".
+func (iw InterwikiLink) String() string {
+	return iw.Prefix + ":" + iw.Target
+}
+
+// Edge gets the EdgeType of the i'th link.
+func (sa *StrippedArticle) Edge(i int) EdgeType {
+	if i < len(sa.Edges) {
+		return sa.Edges[i]
+	}
+	return EdgeProse
+}
+
+// Flag gets the LinkFlags of the i'th link.
+func (sa *StrippedArticle) Flag(i int) LinkFlag {
+	if i < len(sa.Flags) {
+		return sa.Flags[i]
+	}
+	return 0
+}
+
+// Anchor gets the text of the i'th link.
+func (sa *StrippedArticle) Anchor(i int) string {
+	if i < len(sa.Anchors) && sa.Anchors[i] != "" {
+		return sa.Anchors[i]
+	}
+	return sa.Links[i]
+}
+
+// Context gets the sentence the i'th link is in, or "" if it isn't known.
+func (sa *StrippedArticle) Context(i int) string {
+	if i < len(sa.Contexts) {
+		return sa.Contexts[i]
+	}
+	return ""
+}
+
+// Fragment gets the section of the page the i'th link goes to, or "" if
+// it goes to the top.
+func (sa *StrippedArticle) Fragment(i int) string {
+	if i < len(sa.Fragments) {
+		return sa.Fragments[i]
+	}
+	return ""
+}
+
+// Section gets the heading of the section the i'th link is in, or "" if
+// it's in the lead.
+func (sa *StrippedArticle) Section(i int) string {
+	if i < len(sa.Sections) {
+		return sa.Sections[i]
+	}
+	return ""
+}
+
+// DropContext forgets the anchor text and sentence of each link, which are
+// most of the size of an article.
+func (sa *StrippedArticle) DropContext() {
+	sa.Anchors, sa.Contexts = nil, nil
+}
+
+// SetLinks sets the article's links to the articles in `links`, in order,
+// leaving out links to files, other wikis, and pages in namespaces
+// `namespaces` doesn't include. Links to categories set its Categories.
+func (sa *StrippedArticle) SetLinks(links []Link, namespaces *NamespaceFilter) {
+	sa.Links, sa.Edges, sa.Flags = nil, nil, nil
+	sa.Anchors, sa.Contexts = nil, nil
+	sa.Fragments, sa.Sections = nil, nil
+	sa.Categories, sa.Interwiki = nil, nil
+	prose, unflagged := true, true
+	fragments, sections := false, false
+	for _, l := range links {
+		if l.Kind == LinkCategory {
+			sa.addCategory(l.Title())
+			continue
+		}
+		if l.Kind == LinkInterwiki {
+			sa.addInterwiki(InterwikiLink{Prefix: l.Prefix, Target: l.Target})
+			continue
+		}
+		if l.Kind != LinkArticle && !(l.Kind == LinkNamespace && namespaces.Has(l.Namespace)) || l.Target == "" {
+			continue
+		}
+		sa.Links = append(sa.Links, l.Title())
+		sa.Edges = append(sa.Edges, l.Edge)
+		sa.Flags = append(sa.Flags, l.Flags)
+		if l.Anchor == l.Title() {
+			sa.Anchors = append(sa.Anchors, "")
+		} else {
+			sa.Anchors = append(sa.Anchors, l.Anchor)
+		}
+		sa.Contexts = append(sa.Contexts, l.Context)
+		sa.Fragments = append(sa.Fragments, l.Fragment)
+		sa.Sections = append(sa.Sections, l.Section)
+		prose = prose && l.Edge == EdgeProse
+		unflagged = unflagged && l.Flags == 0
+		fragments = fragments || l.Fragment != ""
+		sections = sections || l.Section != ""
+	}
+	if prose {
+		sa.Edges = nil
+	}
+	if unflagged {
+		sa.Flags = nil
+	}
+	if !fragments {
+		sa.Fragments = nil
+	}
+	if !sections {
+		sa.Sections = nil
+	}
+}
+
+// addCategory adds a category to the article, if it isn't already in it.
+func (sa *StrippedArticle) addCategory(title string) {
+	for _, c := range sa.Categories {
+		if c == title {
+			return
+		}
+	}
+	sa.Categories = append(sa.Categories, title)
+}
+
+// addInterwiki adds an interwiki link to the article, if it hasn't already
+// got it.
+func (sa *StrippedArticle) addInterwiki(iw InterwikiLink) {
+	if iw.Target == "" {
+		return
+	}
+	for _, have := range sa.Interwiki {
+		if have == iw {
+			return
+		}
+	}
+	sa.Interwiki = append(sa.Interwiki, iw)
+}
+
+// NewStrippedArticle creates a StrippedArticle from an Article, keeping
+// links to articles in the main namespace.
+// Redirects keep no links: the only one is to their target.
 func NewStrippedArticle(a *Article) *StrippedArticle {
-	return &StrippedArticle{
-		Title:    a.Title,
-		Redirect: a.Redirect.Title,
-		Links:    ParseLinks(a.Text),
-		ID:       a.ID,
+	return DefaultLinkParser.Strip(a, nil)
+}
+
+// Strip creates a StrippedArticle from an Article, keeping links to pages in
+// the namespaces `namespaces` includes.
+func (lp *LinkParser) Strip(a *Article, namespaces *NamespaceFilter) *StrippedArticle {
+	sa := &StrippedArticle{
+		Title:      a.Title,
+		Redirect:   a.Redirect.Title,
+		ID:         a.ID,
+		Namespace:  a.Namespace,
+		RevisionID: a.RevisionID,
+		Size:       len(a.Text),
+	}
+	if t, err := time.Parse(time.RFC3339, a.RevisionTimestamp); err == nil {
+		sa.Timestamp = t
+	}
+	if sa.Redirect == "" {
+		sa.SetLinks(lp.Parse(a.Text), namespaces)
+		sa.Disambiguation = lp.IsDisambiguation(a.Text)
+		sa.Description = lp.ShortDescription(a.Text)
+	}
+	return sa
+}
+
+// articleEncoder writes articles in one of the `WpindexFormat`s.
+type articleEncoder interface {
+	encode(a *StrippedArticle) error
+	close() error
+}
+
+// articleDecoder reads articles in one of the `WpindexFormat`s.
+type articleDecoder interface {
+	decode() (*StrippedArticle, error)
+}
+
+type gobEncoder struct {
+	enc *gob.Encoder
+}
+
+func (ge gobEncoder) encode(a *StrippedArticle) error { return ge.enc.Encode(a) }
+func (ge gobEncoder) close() error                    { return nil }
+
+type gobDecoder struct {
+	dec *gob.Decoder
+}
+
+func (gd gobDecoder) decode() (*StrippedArticle, error) {
+	var a StrippedArticle
+	if err := gd.dec.Decode(&a); err != nil {
+		return nil, err
 	}
+	return &a, nil
 }
 
+// A *.wpindex file is made of one or more segments, each a separate gzip
+// member holding a complete stream in one of the formats. Files are usually
+// a single segment; `WpindexWriter.Checkpoint` starts new ones, so a partly
+// written file can be cut back to a segment boundary and appended to.
+
 // WpindexWriter writes `StrippedArticle`s to a *.wpindex file.
 type WpindexWriter struct {
 	writer     io.Writer
+	format     WpindexFormat
 	gzipWriter *gzip.Writer
-	gobEncoder *gob.Encoder
+	encoder    articleEncoder // nil between segments
+	segments   int
+	err        error
 }
 
-// NewWpindexWriter creates a `WpindexWriter`.
+// NewWpindexWriter creates a `WpindexWriter` which writes the gob format.
 func NewWpindexWriter(f io.Writer) *WpindexWriter {
-	gzipWriter, _ := gzip.NewWriterLevel(f, compressionLevel)
-	gobEncoder := gob.NewEncoder(gzipWriter)
+	return NewWpindexWriterFormat(f, FormatGob)
+}
+
+// NewWpindexWriterFormat creates a `WpindexWriter` which writes articles
+// in the given format.
+func NewWpindexWriterFormat(f io.Writer, format WpindexFormat) *WpindexWriter {
 	return &WpindexWriter{
-		writer:     f,
-		gzipWriter: gzipWriter,
-		gobEncoder: gobEncoder,
+		writer: f,
+		format: format,
+	}
+}
+
+// startSegment starts a new segment, if one isn't already open.
+func (wiw *WpindexWriter) startSegment() {
+	if wiw.encoder != nil || wiw.err != nil {
+		return
+	}
+
+	wiw.gzipWriter, _ = gzip.NewWriterLevel(wiw.writer, compressionLevel)
+	switch wiw.format {
+	case FormatCompact:
+		wiw.encoder, wiw.err = newCompactEncoder(wiw.gzipWriter)
+	default:
+		wiw.encoder = gobEncoder{gob.NewEncoder(wiw.gzipWriter)}
 	}
+	wiw.segments++
+}
+
+// endSegment finishes the open segment, if there is one.
+func (wiw *WpindexWriter) endSegment() {
+	if wiw.encoder == nil || wiw.err != nil {
+		return
+	}
+
+	wiw.err = wiw.encoder.close()
+	gzipErr := wiw.gzipWriter.Close()
+	if wiw.err == nil {
+		wiw.err = gzipErr
+	}
+	wiw.encoder = nil
 }
 
 // WriteArticle writes an article to the *.wpindex file.
 func (wiw *WpindexWriter) WriteArticle(a *StrippedArticle) error {
-	return wiw.gobEncoder.Encode(a)
+	wiw.startSegment()
+	if wiw.err != nil {
+		return wiw.err
+	}
+	return wiw.encoder.encode(a)
+}
+
+// Checkpoint finishes the current segment, so everything written so far
+// can be read back on its own. Later articles go in a new segment.
+func (wiw *WpindexWriter) Checkpoint() error {
+	wiw.endSegment()
+	return wiw.err
 }
 
 // Close closes the `WpindexWriter`.
 func (wiw *WpindexWriter) Close() error {
-	gzipErr := wiw.gzipWriter.Close()
-	return gzipErr
+	if wiw.segments == 0 {
+		wiw.startSegment() // even an empty file needs a segment
+	}
+	wiw.endSegment()
+	return wiw.err
 }
 
 // WpindexReader reads articles from a *.wpindex file.
 type WpindexReader struct {
-	reader     io.Reader
-	gzipReader *gzip.Reader
-	gobDecoder *gob.Decoder
+	reader   io.Reader
+	segments *wpindexSegments
+	decoder  articleDecoder
+
+	// Format is the format of the file, detected from its contents.
+	Format WpindexFormat
 }
 
-// NewWpindexReader creates a `WpindexReader` from an `io.Reader`.
+// NewWpindexReader creates a `WpindexReader` from an `io.Reader`,
+// detecting the format of the file.
 func NewWpindexReader(f io.Reader) (*WpindexReader, error) {
-	gzipReader, err := gzip.NewReader(f)
-	if err != nil {
+	wir := &WpindexReader{
+		reader:   f,
+		segments: newWpindexSegments(f),
+	}
+
+	if err := wir.nextSegment(); err != nil {
 		return nil, err
 	}
-	gobDecoder := gob.NewDecoder(gzipReader)
-	return &WpindexReader{
-		reader:     f,
-		gzipReader: gzipReader,
-		gobDecoder: gobDecoder,
-	}, nil
+
+	return wir, nil
 }
 
-// ReadArticle reads an article from the `WpindexReader`
+// nextSegment opens a decoder on the next segment of the file.
+func (wir *WpindexReader) nextSegment() error {
+	buf, format, err := wir.segments.next()
+	if err != nil {
+		return err
+	}
+
+	wir.Format = format
+	if format == FormatCompact {
+		wir.decoder, err = newCompactDecoder(buf)
+	} else {
+		wir.decoder = gobDecoder{gob.NewDecoder(buf)}
+	}
+	return err
+}
+
+// wpindexSegments iterates over the segments of a *.wpindex file.
+type wpindexSegments struct {
+	src        *bufio.Reader
+	gzipReader *gzip.Reader
+}
+
+func newWpindexSegments(f io.Reader) *wpindexSegments {
+	return &wpindexSegments{src: bufio.NewReader(f)}
+}
+
+// next opens the next segment and detects its format. It returns `EOF`
+// when there are no segments left.
+func (ws *wpindexSegments) next() (*bufio.Reader, WpindexFormat, error) {
+	var err error
+	if ws.gzipReader == nil {
+		ws.gzipReader, err = gzip.NewReader(ws.src)
+	} else {
+		err = ws.gzipReader.Reset(ws.src)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	ws.gzipReader.Multistream(false)
+
+	buf := bufio.NewReader(ws.gzipReader)
+	magic, _ := buf.Peek(len(compactMagic))
+	if string(magic) == compactMagic {
+		return buf, FormatCompact, nil
+	}
+	return buf, FormatGob, nil
+}
+
+func (ws *wpindexSegments) close() error {
+	if ws.gzipReader == nil {
+		return nil
+	}
+	return ws.gzipReader.Close()
+}
+
+// ReadArticle reads an article from the `WpindexReader`. It returns `EOF`
+// at the end of the file.
 func (wir *WpindexReader) ReadArticle() (*StrippedArticle, error) {
-	var a StrippedArticle
-	err := wir.gobDecoder.Decode(&a)
-	return &a, err
+	for {
+		a, err := wir.decoder.decode()
+		if err != EOF {
+			return a, err
+		}
+
+		// End of this segment, move on to the next.
+		if err := wir.nextSegment(); err != nil {
+			return nil, err
+		}
+	}
 }
 
 // Close closes the `WpindexReader`.
 func (wir *WpindexReader) Close() error {
-	return wir.gzipReader.Close()
+	return wir.segments.close()
 }
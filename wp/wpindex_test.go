@@ -0,0 +1,278 @@
+package wikipath
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testArticles = []*StrippedArticle{
+	{Title: "A", ID: 1, Links: []string{"B", "C"}, Anchors: []string{"", "see"}, Contexts: []string{"A is B.", "And so we see C."},
+		Fragments: []string{"Top", ""}, Sections: []string{"", "History"}},
+	{Title: "B", ID: 2, Links: []string{"A", "C", "D"}, Edges: []EdgeType{EdgeProse, EdgeNavbox, EdgeInfobox | EdgeList}},
+	{Title: "C", ID: 3, Links: []string{"B", "E"}, Flags: []LinkFlag{LinkParens | LinkItalic, 0},
+		RevisionID: 1200, Timestamp: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), Size: 4821, Description: "Letter of the alphabet"},
+	{Title: "D", ID: 4, Disambiguation: true, Interwiki: []InterwikiLink{{Prefix: "fr", Target: "D"}, {Prefix: "wikt", Target: "d"}}},
+	{Title: "E", ID: 5, Redirect: "B"},
+	{Title: "Négatif", ID: -6, Links: []string{"", "A"}},
+	{Title: "Portal:A", ID: 7, Namespace: 100, Links: []string{"A"}, Categories: []string{"Category:Portals", "Category:A"}},
+}
+
+func writeWpindex(t testing.TB, format WpindexFormat, articles []*StrippedArticle) []byte {
+	var buf bytes.Buffer
+	w := NewWpindexWriterFormat(&buf, format)
+	for _, a := range articles {
+		if err := w.WriteArticle(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readWpindex(t testing.TB, data []byte) ([]*StrippedArticle, WpindexFormat, error) {
+	r, err := NewWpindexReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var articles []*StrippedArticle
+	for {
+		a, err := r.ReadArticle()
+		if err == EOF {
+			return articles, r.Format, nil
+		} else if err != nil {
+			return articles, r.Format, err
+		}
+		articles = append(articles, a)
+	}
+}
+
+// gunzip returns the decompressed contents of a *.wpindex file.
+func gunzip(t testing.TB, data []byte) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func regzip(raw []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(raw)
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestWpindexRoundTrip(t *testing.T) {
+	for _, format := range []WpindexFormat{FormatGob, FormatCompact} {
+		t.Run(format.String(), func(t *testing.T) {
+			data := writeWpindex(t, format, testArticles)
+			articles, detected, err := readWpindex(t, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, detected, format)
+			assertEqual(t, len(articles), len(testArticles))
+			for i, a := range articles {
+				if !reflect.DeepEqual(a, testArticles[i]) {
+					t.Fatalf("article %d: got %+v, want %+v", i, a, testArticles[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWpindexCompactBlocks(t *testing.T) {
+	// Enough articles to span several blocks.
+	var articles []*StrippedArticle
+	for i := 0; i < blockRecords*3+7; i++ {
+		articles = append(articles, &StrippedArticle{
+			Title: strings.Repeat("x", i%13),
+			ID:    i,
+			Links: []string{"Shared", strings.Repeat("y", i%5)},
+		})
+	}
+
+	data := writeWpindex(t, FormatCompact, articles)
+	read, _, err := readWpindex(t, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, articles) {
+		t.Fatal("articles differ after round trip")
+	}
+}
+
+func TestWpindexCompactCorrupt(t *testing.T) {
+	raw := gunzip(t, writeWpindex(t, FormatCompact, testArticles))
+
+	t.Run("Checksum", func(t *testing.T) {
+		bad := append([]byte(nil), raw...)
+		bad[len(compactMagic)+8] ^= 0x40
+		_, _, err := readWpindex(t, regzip(bad))
+		if err == nil {
+			t.Fatal("corrupt block was read without error")
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		// Drop the footer.
+		_, _, err := readWpindex(t, regzip(raw[:len(raw)-7]))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+		}
+	})
+}
+
+func FuzzCompactRoundTrip(f *testing.F) {
+	f.Add("Sandbox", 42, "", "Fox\nQueen (band)\nFox")
+	f.Add("", -1, "Target", "")
+	f.Fuzz(func(t *testing.T, title string, id int, redirect string, links string) {
+		a := &StrippedArticle{Title: title, ID: id, Redirect: redirect}
+		if links != "" {
+			a.Links = strings.Split(links, "\n")
+		}
+
+		articles, _, err := readWpindex(t, writeWpindex(t, FormatCompact, []*StrippedArticle{a}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(articles) != 1 || !reflect.DeepEqual(articles[0], a) {
+			t.Fatalf("got %+v, want %+v", articles, a)
+		}
+	})
+}
+
+func FuzzCompactDecode(f *testing.F) {
+	f.Add(gunzip(f, writeWpindex(f, FormatCompact, testArticles)))
+	f.Add([]byte(compactMagic))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cd, err := newCompactDecoder(bufio.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return
+		}
+		for i := 0; i < 1e5; i++ {
+			if _, err := cd.decode(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkWpindexFormat compares the size and speed of each format, on
+// the articles of -archivePath. The archive can be compressed, like the
+// dumps download.sh fetches.
+func BenchmarkWpindexFormat(b *testing.B) {
+	archiveFile, fileErr := os.Open(*wikiArchivePath)
+	checkError(b, fileErr)
+	defer archiveFile.Close()
+	info, statErr := archiveFile.Stat()
+	checkError(b, statErr)
+	kind, kindErr := DetectArchive(archiveFile, info.Size())
+	checkError(b, kindErr)
+	xml, openErr := OpenArchive(archiveFile, kind)
+	checkError(b, openErr)
+
+	var articles []*StrippedArticle
+	LoadWiki(xml, func(a *Article) bool {
+		articles = append(articles, NewStrippedArticle(a))
+		return true
+	})
+
+	for _, format := range []WpindexFormat{FormatGob, FormatCompact} {
+		var data []byte
+
+		b.Run("Write/"+format.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				data = writeWpindex(b, format, articles)
+			}
+			b.ReportMetric(float64(len(data)), "bytes")
+			b.ReportMetric(float64(len(gunzip(b, data))), "raw-bytes")
+		})
+
+		b.Run("Read/"+format.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _, err := readWpindex(b, data)
+				checkError(b, err)
+			}
+		})
+	}
+}
+
+func TestWpindexSegments(t *testing.T) {
+	for _, format := range []WpindexFormat{FormatGob, FormatCompact} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWpindexWriterFormat(&buf, format)
+			var boundary int
+			for i, a := range testArticles {
+				if err := w.WriteArticle(a); err != nil {
+					t.Fatal(err)
+				}
+				if i == 2 {
+					if err := w.Checkpoint(); err != nil {
+						t.Fatal(err)
+					}
+					boundary = buf.Len()
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			articles, _, err := readWpindex(t, buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(articles, testArticles) {
+				t.Fatal("articles differ after round trip")
+			}
+
+			// Cut back to the checkpoint, and append the rest in a new writer.
+			resumed := bytes.NewBuffer(buf.Bytes()[:boundary:boundary])
+			w = NewWpindexWriterFormat(resumed, format)
+			for _, a := range testArticles[3:] {
+				w.WriteArticle(a)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			articles, _, err = readWpindex(t, resumed.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(articles, testArticles) {
+				t.Fatal("articles differ after resuming")
+			}
+
+			report, issues := verify(t, resumed.Bytes())
+			assertEqual(t, report.Segments, 2)
+			assertEqual(t, report.Records, len(testArticles))
+			assertEqual(t, report.OK(), true)
+			assertEqual(t, len(issues), 1) // the dangling "" link
+		})
+	}
+}
+
+func TestWpindexEmpty(t *testing.T) {
+	for _, format := range []WpindexFormat{FormatGob, FormatCompact} {
+		articles, _, err := readWpindex(t, writeWpindex(t, format, nil))
+		assertEqual(t, err, nil)
+		assertEqual(t, len(articles), 0)
+	}
+}
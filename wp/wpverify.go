@@ -0,0 +1,293 @@
+package wikipath
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// IssueKind is a kind of problem found in a *.wpindex file.
+type IssueKind string
+
+// Structural issues: the file can't be read back completely.
+const (
+	IssueChecksum  IssueKind = "checksum"  // A block or footer failed its checksum.
+	IssueCorrupt   IssueKind = "corrupt"   // A record or block couldn't be decoded.
+	IssueTruncated IssueKind = "truncated" // The file ends before its footer.
+	IssueCount     IssueKind = "count"     // The footer disagrees with what was read.
+)
+
+// Semantic issues: the file reads fine, but the articles in it are odd.
+const (
+	IssueDuplicateTitle IssueKind = "duplicate-title" // Two articles normalize to the same title.
+	IssueEmptyTitle     IssueKind = "empty-title"     // An article has no title.
+	IssueRedirectLinks  IssueKind = "redirect-links"  // A redirect also has links.
+	IssueDanglingLink   IssueKind = "dangling-link"   // A link or redirect points to no article.
+)
+
+// Structural returns true if issues of this kind mean the file is damaged.
+func (k IssueKind) Structural() bool {
+	switch k {
+	case IssueChecksum, IssueCorrupt, IssueTruncated, IssueCount:
+		return true
+	default:
+		return false
+	}
+}
+
+// VerifyIssue is a problem found in a *.wpindex file.
+type VerifyIssue struct {
+	Kind    IssueKind `json:"kind"`
+	Segment int       `json:"segment"`         // Segment of the file the issue is in.
+	Offset  int64     `json:"offset"`          // Offset in the segment's decompressed stream, -1 if unknown.
+	Title   string    `json:"title,omitempty"` // Article the issue concerns, if any.
+	Detail  string    `json:"detail"`
+}
+
+func (vi *VerifyIssue) String() string {
+	str := string(vi.Kind)
+	if vi.Offset >= 0 {
+		str += fmt.Sprintf(" @%d:%d", vi.Segment, vi.Offset)
+	}
+	if vi.Title != "" {
+		str += fmt.Sprintf(" '%s'", vi.Title)
+	}
+	return str + ": " + vi.Detail
+}
+
+// VerifyReport summarizes a verified *.wpindex file.
+type VerifyReport struct {
+	Format    WpindexFormat // Format of the first segment.
+	Segments  int
+	Records   int  // Records decoded.
+	Blocks    int  // Blocks read, for the compact format.
+	Footer    bool // If every segment ended with a footer.
+	Articles  int
+	Redirects int
+	Links     int
+	Issues    map[IssueKind]int
+}
+
+// OK returns true if no structural issues were found.
+func (vr *VerifyReport) OK() bool {
+	for kind, n := range vr.Issues {
+		if kind.Structural() && n > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// verifier keeps the state of a VerifyWpindex run.
+type verifier struct {
+	report  *VerifyReport
+	issue   func(*VerifyIssue)
+	segment int
+
+	titles  map[string]bool   // Normalized titles seen.
+	targets map[string]string // Normalized link target -> first article linking to it.
+}
+
+func (v *verifier) add(kind IssueKind, offset int64, title string, detail string, args ...interface{}) {
+	v.report.Issues[kind]++
+	v.issue(&VerifyIssue{
+		Kind:    kind,
+		Segment: v.segment,
+		Offset:  offset,
+		Title:   title,
+		Detail:  fmt.Sprintf(detail, args...),
+	})
+}
+
+// article checks the per-article invariants and records titles and links
+// for the dangling link check at the end.
+func (v *verifier) article(a *StrippedArticle, offset int64) {
+	v.report.Records++
+
+	if a.Title == "" {
+		v.add(IssueEmptyTitle, offset, "", "article %d has an empty title", a.ID)
+	} else {
+		k := NormalizeArticleTitle(a.Title)
+		if v.titles[k] {
+			v.add(IssueDuplicateTitle, offset, a.Title, "title already used by another article")
+		}
+		v.titles[k] = true
+	}
+
+	if a.Redirect != "" {
+		v.report.Redirects++
+		if len(a.Links) > 0 {
+			v.add(IssueRedirectLinks, offset, a.Title, "redirect to '%s' has %d links", a.Redirect, len(a.Links))
+		}
+		v.target(a.Redirect, a.Title)
+	} else {
+		v.report.Articles++
+		for _, l := range a.Links {
+			v.target(l, a.Title)
+		}
+	}
+	v.report.Links += len(a.Links)
+}
+
+func (v *verifier) target(link string, from string) {
+	k := NormalizeArticleTitle(link)
+	if _, ok := v.targets[k]; !ok {
+		v.targets[k] = from
+	}
+}
+
+// dangling reports every link target that isn't the title of an article.
+func (v *verifier) dangling() {
+	var missing []string
+	for k := range v.targets {
+		if !v.titles[k] {
+			missing = append(missing, k)
+		}
+	}
+	sort.Strings(missing)
+	for _, k := range missing {
+		v.add(IssueDanglingLink, -1, v.targets[k], "links to missing article '%s'", k)
+	}
+}
+
+// VerifyWpindex streams a *.wpindex file, calling `issue` for each problem
+// it finds. Structural problems (checksums, undecodable records, a missing
+// or wrong footer) are found as the file is read; semantic ones (duplicate
+// and empty titles, redirects with links, dangling links) need only the set
+// of titles and link targets in memory, not the articles themselves.
+//
+// The returned error is only non-nil if the file can't be opened at all.
+func VerifyWpindex(f io.Reader, issue func(*VerifyIssue)) (*VerifyReport, error) {
+	segments := newWpindexSegments(f)
+	defer segments.close()
+
+	v := &verifier{
+		report:  &VerifyReport{Footer: true, Issues: make(map[IssueKind]int)},
+		issue:   issue,
+		titles:  make(map[string]bool),
+		targets: make(map[string]string),
+	}
+
+	for ; ; v.segment++ {
+		buf, format, err := segments.next()
+		if err == EOF && v.segment > 0 {
+			break
+		} else if err != nil && v.segment == 0 {
+			return nil, err
+		} else if err != nil {
+			v.add(IssueCorrupt, -1, "", "unreadable segment: %v", err)
+			break
+		}
+
+		if v.segment == 0 {
+			v.report.Format = format
+		}
+		v.report.Segments++
+
+		if format == FormatCompact {
+			v.compact(buf)
+		} else {
+			v.report.Footer = false
+			v.gob(buf)
+		}
+	}
+
+	v.dangling()
+	return v.report, nil
+}
+
+func (v *verifier) compact(buf *bufio.Reader) {
+	cd, err := newCompactDecoder(buf)
+	if err != nil {
+		v.add(IssueCorrupt, 0, "", "bad header: %v", err)
+		return
+	}
+
+	for {
+		block, footer, err := cd.readBlock()
+		if err == io.ErrUnexpectedEOF {
+			v.report.Footer = false
+			v.add(IssueTruncated, cd.offset, "", "segment ends without a footer")
+			return
+		} else if err != nil {
+			v.add(IssueCorrupt, cd.offset, "", "unreadable block: %v", err)
+			return
+		}
+
+		if footer != nil {
+			if !footer.Valid {
+				v.add(IssueChecksum, footer.Offset, "", "footer checksum mismatch")
+			}
+			if footer.Records != cd.records {
+				v.add(IssueCount, footer.Offset, "", "footer expects %d records, read %d", footer.Records, cd.records)
+			}
+			if footer.Blocks != cd.blocks {
+				v.add(IssueCount, footer.Offset, "", "footer expects %d blocks, read %d", footer.Blocks, cd.blocks)
+			}
+			if _, err := buf.Peek(1); err != io.EOF {
+				v.add(IssueCorrupt, cd.offset, "", "trailing data after footer")
+			}
+			return
+		}
+
+		v.report.Blocks++
+		if !block.Valid {
+			v.add(IssueChecksum, block.Offset, "", "block of %d records fails its checksum", len(block.Records))
+		}
+
+		for i, rec := range block.Records {
+			a, err := decodeCompactRecord(rec, block.Dict)
+			if err != nil {
+				v.add(IssueCorrupt, block.Offsets[i], "", "undecodable record: %v", err)
+				continue
+			}
+			v.article(a, block.Offsets[i])
+		}
+	}
+}
+
+func (v *verifier) gob(buf *bufio.Reader) {
+	// gob doesn't buffer a reader which is also an io.ByteReader, so counting
+	// what it reads gives exact record offsets.
+	cr := &countingReader{r: buf}
+	dec := gob.NewDecoder(cr)
+
+	for {
+		offset := cr.n
+		var a StrippedArticle
+		err := dec.Decode(&a)
+		if err == io.EOF {
+			return
+		} else if err == io.ErrUnexpectedEOF {
+			v.add(IssueTruncated, offset, "", "file ends in the middle of a record")
+			return
+		} else if err != nil {
+			// gob can't resynchronize after a bad message.
+			v.add(IssueCorrupt, offset, "", "undecodable record: %v", err)
+			return
+		}
+		v.article(&a, offset)
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
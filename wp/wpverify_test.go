@@ -0,0 +1,99 @@
+package wikipath
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func verify(t *testing.T, data []byte) (*VerifyReport, []*VerifyIssue) {
+	var issues []*VerifyIssue
+	report, err := VerifyWpindex(bytes.NewReader(data), func(vi *VerifyIssue) {
+		t.Log(vi)
+		issues = append(issues, vi)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report, issues
+}
+
+var cleanArticles = []*StrippedArticle{
+	{Title: "A", ID: 1, Links: []string{"B", "c"}},
+	{Title: "B", ID: 2, Links: []string{"A"}},
+	{Title: "C", ID: 3},
+	{Title: "See C", ID: 4, Redirect: "C"},
+}
+
+func TestVerifyClean(t *testing.T) {
+	for _, format := range []WpindexFormat{FormatGob, FormatCompact} {
+		t.Run(format.String(), func(t *testing.T) {
+			report, issues := verify(t, writeWpindex(t, format, cleanArticles))
+			assertEqual(t, len(issues), 0)
+			assertEqual(t, report.OK(), true)
+			assertEqual(t, report.Records, 4)
+			assertEqual(t, report.Articles, 3)
+			assertEqual(t, report.Redirects, 1)
+			assertEqual(t, report.Footer, format == FormatCompact)
+		})
+	}
+}
+
+func TestVerifySemantic(t *testing.T) {
+	articles := []*StrippedArticle{
+		{Title: "A", ID: 1, Links: []string{"Nowhere"}},
+		{Title: "a", ID: 2},
+		{Title: "", ID: 3},
+		{Title: "R", ID: 4, Redirect: "A", Links: []string{"A"}},
+	}
+
+	report, _ := verify(t, writeWpindex(t, FormatCompact, articles))
+	assertEqual(t, report.OK(), true)
+	assertEqual(t, report.Issues[IssueDuplicateTitle], 1)
+	assertEqual(t, report.Issues[IssueEmptyTitle], 1)
+	assertEqual(t, report.Issues[IssueRedirectLinks], 1)
+	assertEqual(t, report.Issues[IssueDanglingLink], 1)
+}
+
+func TestVerifyStructural(t *testing.T) {
+	raw := gunzip(t, writeWpindex(t, FormatCompact, cleanArticles))
+
+	t.Run("Checksum", func(t *testing.T) {
+		bad := append([]byte(nil), raw...)
+		bad[len(bad)-1] ^= 0xff // footer checksum
+		report, _ := verify(t, regzip(bad))
+		assertEqual(t, report.OK(), false)
+		assertEqual(t, report.Issues[IssueChecksum], 1)
+		assertEqual(t, report.Records, 4)
+	})
+
+	t.Run("Record", func(t *testing.T) {
+		bad := append([]byte(nil), raw...)
+		// Make the first record's title run past the end of the record.
+		cd, _ := newCompactDecoder(newBufReader(raw))
+		block, _, _ := cd.readBlock()
+		bad[block.Offsets[0]+1] = 0x7f
+
+		report, issues := verify(t, regzip(bad))
+		assertEqual(t, report.Issues[IssueCorrupt], 1)
+		assertEqual(t, issues[1].Offset, block.Offsets[0])
+		assertEqual(t, report.Records, 3)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		report, _ := verify(t, regzip(raw[:len(raw)-6]))
+		assertEqual(t, report.Issues[IssueTruncated], 1)
+		assertEqual(t, report.Footer, false)
+	})
+
+	t.Run("TruncatedGob", func(t *testing.T) {
+		raw := gunzip(t, writeWpindex(t, FormatGob, cleanArticles))
+		report, _ := verify(t, regzip(raw[:len(raw)-3]))
+		assertEqual(t, report.Issues[IssueTruncated], 1)
+		assertEqual(t, report.Records, 3)
+	})
+}
+
+func newBufReader(data []byte) *bufio.Reader {
+	return bufio.NewReader(bytes.NewReader(data))
+}
@@ -0,0 +1,380 @@
+package wikipath
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// zimMagic is the first 4 bytes of a ZIM file, "ZIM\x04" read little-endian.
+const zimMagic = 72173914
+
+// Mime types of the special kinds of ZIM directory entry.
+const (
+	zimRedirect   = 0xffff
+	zimLinkTarget = 0xfffe
+	zimDeleted    = 0xfffd
+)
+
+// Compression types of ZIM clusters.
+const (
+	zimNone  = 1
+	zimZlib  = 2
+	zimBzip2 = 3
+	zimXz    = 4
+	zimZstd  = 5
+)
+
+// ErrZimCompression is returned for ZIM clusters compressed with something
+// there's no decoder for in the standard library (xz and zstd).
+var ErrZimCompression = errors.New("zim: xz and zstd compressed ZIM files aren't supported, only zlib and bzip2")
+
+// ZimHeader is the header at the start of a ZIM file.
+// https://wiki.openzim.org/wiki/ZIM_file_format
+type ZimHeader struct {
+	Magic         uint32
+	MajorVersion  uint16
+	MinorVersion  uint16
+	UUID          [16]byte
+	EntryCount    uint32
+	ClusterCount  uint32
+	PathPtrPos    uint64
+	TitlePtrPos   uint64
+	ClusterPtrPos uint64
+	MimeListPos   uint64
+	MainPage      uint32
+	LayoutPage    uint32
+	ChecksumPos   uint64
+}
+
+// ZimEntry is an entry in a ZIM file's directory: an article, a redirect,
+// or some other file like an image or stylesheet.
+type ZimEntry struct {
+	Index     uint32
+	Mime      uint16
+	Namespace byte
+	Path      string
+	Title     string // The path, if the entry has no title of its own.
+
+	Cluster  uint32 // Content entries only.
+	Blob     uint32
+	Redirect uint32 // Redirects only: the index of the entry redirected to.
+}
+
+// IsRedirect returns true if the entry redirects to another.
+func (ze *ZimEntry) IsRedirect() bool {
+	return ze.Mime == zimRedirect
+}
+
+// fullPath is the entry's path with its namespace, like links use.
+func (ze *ZimEntry) fullPath() string {
+	return string(ze.Namespace) + "/" + ze.Path
+}
+
+// ZimReader reads a ZIM file, like the offline Wikipedias Kiwix uses.
+type ZimReader struct {
+	r         io.ReaderAt
+	Header    ZimHeader
+	MimeTypes []string
+
+	cluster     uint32 // Cluster whose blobs are cached.
+	clusterData []byte
+	blobOffsets []uint64
+}
+
+// NewZimReader reads the header and mime type list of a ZIM file.
+func NewZimReader(r io.ReaderAt) (*ZimReader, error) {
+	zr := &ZimReader{r: r, cluster: math.MaxUint32}
+
+	headerRaw := io.NewSectionReader(r, 0, int64(binary.Size(zr.Header)))
+	if err := binary.Read(headerRaw, binary.LittleEndian, &zr.Header); err != nil {
+		return nil, fmt.Errorf("zim: reading header: %v", err)
+	}
+	if zr.Header.Magic != zimMagic {
+		return nil, errors.New("zim: not a ZIM file")
+	}
+
+	mimes := bufio.NewReader(io.NewSectionReader(r, int64(zr.Header.MimeListPos), math.MaxInt64-int64(zr.Header.MimeListPos)))
+	for {
+		mime, err := mimes.ReadString(0)
+		if err != nil {
+			return nil, fmt.Errorf("zim: reading mime types: %v", noEOF(err))
+		}
+		if mime == "\x00" {
+			break
+		}
+		zr.MimeTypes = append(zr.MimeTypes, strings.TrimSuffix(mime, "\x00"))
+	}
+
+	return zr, nil
+}
+
+func (zr *ZimReader) uint64At(pos uint64) (uint64, error) {
+	var buf [8]byte
+	if _, err := zr.r.ReadAt(buf[:], int64(pos)); err != nil {
+		return 0, noEOF(err)
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+// CheckCompression returns ErrZimCompression if any cluster is compressed
+// with xz or zstd, as most ZIM files Kiwix publishes are, so they can be
+// turned away before reading any articles.
+func (zr *ZimReader) CheckCompression() error {
+	ptrs := make([]byte, 8*uint64(zr.Header.ClusterCount))
+	if _, err := zr.r.ReadAt(ptrs, int64(zr.Header.ClusterPtrPos)); err != nil {
+		return fmt.Errorf("zim: reading cluster pointers: %v", noEOF(err))
+	}
+	var info [1]byte
+	for i := 0; i < len(ptrs); i += 8 {
+		pos := binary.LittleEndian.Uint64(ptrs[i:])
+		if _, err := zr.r.ReadAt(info[:], int64(pos)); err != nil {
+			return noEOF(err)
+		}
+		if c := info[0] & 0x0f; c == zimXz || c == zimZstd {
+			return ErrZimCompression
+		}
+	}
+	return nil
+}
+
+// Entry reads the directory entry at `index`, in path order.
+func (zr *ZimReader) Entry(index uint32) (*ZimEntry, error) {
+	if index >= zr.Header.EntryCount {
+		return nil, fmt.Errorf("zim: entry %d out of range", index)
+	}
+	pos, err := zr.uint64At(zr.Header.PathPtrPos + 8*uint64(index))
+	if err != nil {
+		return nil, err
+	}
+
+	er := bufio.NewReader(io.NewSectionReader(zr.r, int64(pos), math.MaxInt64-int64(pos)))
+	var head struct {
+		Mime         uint16
+		ParameterLen uint8
+		Namespace    byte
+		Revision     uint32
+	}
+	if err := binary.Read(er, binary.LittleEndian, &head); err != nil {
+		return nil, noEOF(err)
+	}
+
+	ze := &ZimEntry{Index: index, Mime: head.Mime, Namespace: head.Namespace}
+	switch head.Mime {
+	case zimRedirect:
+		err = binary.Read(er, binary.LittleEndian, &ze.Redirect)
+	case zimLinkTarget, zimDeleted:
+		// No more fields.
+	default:
+		var loc [2]uint32
+		err = binary.Read(er, binary.LittleEndian, &loc)
+		ze.Cluster, ze.Blob = loc[0], loc[1]
+	}
+	if err != nil {
+		return nil, noEOF(err)
+	}
+
+	if ze.Path, err = er.ReadString(0); err != nil {
+		return nil, noEOF(err)
+	}
+	if ze.Title, err = er.ReadString(0); err != nil {
+		return nil, noEOF(err)
+	}
+	ze.Path = strings.TrimSuffix(ze.Path, "\x00")
+	ze.Title = strings.TrimSuffix(ze.Title, "\x00")
+	if ze.Title == "" {
+		ze.Title = ze.Path
+	}
+	return ze, nil
+}
+
+// MimeType returns the mime type of a content entry.
+func (zr *ZimReader) MimeType(ze *ZimEntry) string {
+	if int(ze.Mime) < len(zr.MimeTypes) {
+		return zr.MimeTypes[ze.Mime]
+	}
+	return ""
+}
+
+// Blob reads the content of an entry. The last cluster read is kept, so
+// reading entries in cluster order decompresses each cluster once.
+func (zr *ZimReader) Blob(ze *ZimEntry) ([]byte, error) {
+	if ze.Cluster != zr.cluster {
+		if err := zr.loadCluster(ze.Cluster); err != nil {
+			return nil, err
+		}
+	}
+	if int(ze.Blob)+1 >= len(zr.blobOffsets) {
+		return nil, fmt.Errorf("zim: blob %d out of range in cluster %d", ze.Blob, ze.Cluster)
+	}
+	start, end := zr.blobOffsets[ze.Blob], zr.blobOffsets[ze.Blob+1]
+	if start > end || end > uint64(len(zr.clusterData)) {
+		return nil, fmt.Errorf("zim: bad blob offsets in cluster %d", ze.Cluster)
+	}
+	return zr.clusterData[start:end], nil
+}
+
+// loadCluster decompresses a cluster, and reads its blob offsets.
+func (zr *ZimReader) loadCluster(cluster uint32) error {
+	zr.cluster = math.MaxUint32
+	if cluster >= zr.Header.ClusterCount {
+		return fmt.Errorf("zim: cluster %d out of range", cluster)
+	}
+	pos, err := zr.uint64At(zr.Header.ClusterPtrPos + 8*uint64(cluster))
+	if err != nil {
+		return err
+	}
+
+	var info [1]byte
+	if _, err := zr.r.ReadAt(info[:], int64(pos)); err != nil {
+		return noEOF(err)
+	}
+	raw := io.NewSectionReader(zr.r, int64(pos)+1, math.MaxInt64-int64(pos)-1)
+
+	var data io.Reader
+	switch info[0] & 0x0f {
+	case 0, zimNone:
+		data = raw
+	case zimZlib:
+		if data, err = zlib.NewReader(raw); err != nil {
+			return err
+		}
+	case zimBzip2:
+		data = bzip2.NewReader(raw)
+	case zimXz, zimZstd:
+		return ErrZimCompression
+	default:
+		return fmt.Errorf("zim: unknown compression %d in cluster %d", info[0]&0x0f, cluster)
+	}
+	data = bufio.NewReader(data)
+
+	// Offsets are 8 bytes in extended clusters, or else 4. The first says
+	// how many there are.
+	offsetSize := uint64(4)
+	if info[0]&0x10 != 0 {
+		offsetSize = 8
+	}
+	readOffset := func() (uint64, error) {
+		var buf [8]byte
+		if _, err := io.ReadFull(data, buf[:offsetSize]); err != nil {
+			return 0, noEOF(err)
+		}
+		return binary.LittleEndian.Uint64(buf[:]), nil
+	}
+
+	first, err := readOffset()
+	if err != nil {
+		return err
+	}
+	if first < offsetSize || first%offsetSize != 0 || first > uint64(maxBlockBytes) {
+		return fmt.Errorf("zim: bad offsets in cluster %d", cluster)
+	}
+	offsets := []uint64{first}
+	for i := uint64(1); i < first/offsetSize; i++ {
+		off, err := readOffset()
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, off)
+	}
+
+	// Read up to the end of the last blob, with the offsets in front so they
+	// index straight into it.
+	last := offsets[len(offsets)-1]
+	if last < first || last > uint64(maxBlockBytes) {
+		return fmt.Errorf("zim: bad offsets in cluster %d", cluster)
+	}
+	body := make([]byte, last)
+	if _, err := io.ReadFull(data, body[first:]); err != nil {
+		return noEOF(err)
+	}
+
+	zr.cluster = cluster
+	zr.clusterData = body
+	zr.blobOffsets = offsets
+	return nil
+}
+
+// isZimArticle returns true for the entries which are articles: HTML pages
+// in the 'A' namespace of older files, or the 'C' namespace of newer ones.
+func (zr *ZimReader) isZimArticle(ze *ZimEntry) bool {
+	if ze.Namespace != 'A' && ze.Namespace != 'C' {
+		return false
+	}
+	return ze.IsRedirect() || strings.HasPrefix(zr.MimeType(ze), "text/html")
+}
+
+// LoadZim reads the articles and redirects out of a ZIM file, calling
+// `visitor` for each one and stopping if it returns false. Links are taken
+// from the `<a href>`s of each article which lead to other articles.
+//
+// ZIM files have no page IDs, so articles are numbered by their position in
+// the file's directory, which is stable for a given file. Clusters compressed
+// with xz or zstd, as most recent ZIM files are, give ErrZimCompression;
+// CheckCompression finds them up front.
+func LoadZim(zr *ZimReader, visitor func(*StrippedArticle) bool) error {
+	// Read the whole directory, to resolve links and redirects.
+	entries := make([]*ZimEntry, 0, zr.Header.EntryCount)
+	byPath := make(map[string]*ZimEntry)
+	for i := uint32(0); i < zr.Header.EntryCount; i++ {
+		ze, err := zr.Entry(i)
+		if err != nil {
+			return err
+		}
+		if zr.isZimArticle(ze) {
+			entries = append(entries, ze)
+			byPath[ze.fullPath()] = ze
+		}
+	}
+
+	// Read the articles in cluster order, so each cluster's only read once.
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsRedirect() != b.IsRedirect() {
+			return b.IsRedirect()
+		}
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		return a.Blob < b.Blob
+	})
+
+	for _, ze := range entries {
+		sa := &StrippedArticle{Title: ze.Title, ID: int(ze.Index) + 1}
+
+		if ze.IsRedirect() {
+			target, err := zr.Entry(ze.Redirect)
+			if err != nil {
+				return err
+			}
+			sa.Redirect = target.Title
+		} else {
+			content, err := zr.Blob(ze)
+			if err != nil {
+				return fmt.Errorf("zim: reading '%s': %v", ze.Path, err)
+			}
+			for _, href := range ParseHTMLLinks(string(content)) {
+				p, ok := resolveHTMLLink(ze.fullPath(), href)
+				if !ok {
+					continue
+				}
+				if dst := byPath[p]; dst != nil {
+					sa.Links = append(sa.Links, dst.Title)
+				}
+			}
+		}
+
+		if !visitor(sa) {
+			return ErrStopped
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package wikipath
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// zimTestEntry is an entry for buildZim.
+type zimTestEntry struct {
+	ns       byte
+	path     string
+	title    string
+	mime     string
+	content  string
+	redirect string // Path of the entry redirected to, in the same namespace.
+}
+
+// buildZim writes a ZIM file holding `entries`, with all the content in one
+// cluster compressed as `compression`.
+func buildZim(t *testing.T, entries []zimTestEntry, compression byte) []byte {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].ns != entries[j].ns {
+			return entries[i].ns < entries[j].ns
+		}
+		return entries[i].path < entries[j].path
+	})
+
+	var mimes []string
+	mimeIndex := make(map[string]uint16)
+	pathIndex := make(map[string]uint32)
+	for i, e := range entries {
+		pathIndex[string(e.ns)+e.path] = uint32(i)
+		if _, ok := mimeIndex[e.mime]; !ok && e.redirect == "" {
+			mimeIndex[e.mime] = uint16(len(mimes))
+			mimes = append(mimes, e.mime)
+		}
+	}
+
+	// The cluster: blob offsets, then the blobs.
+	var blobs [][]byte
+	var dirents [][]byte
+	for _, e := range entries {
+		var d bytes.Buffer
+		if e.redirect != "" {
+			binary.Write(&d, binary.LittleEndian, uint16(zimRedirect))
+			d.Write([]byte{0, e.ns, 0, 0, 0, 0})
+			binary.Write(&d, binary.LittleEndian, pathIndex[string(e.ns)+e.redirect])
+		} else {
+			binary.Write(&d, binary.LittleEndian, mimeIndex[e.mime])
+			d.Write([]byte{0, e.ns, 0, 0, 0, 0})
+			binary.Write(&d, binary.LittleEndian, [2]uint32{0, uint32(len(blobs))})
+			blobs = append(blobs, []byte(e.content))
+		}
+		d.WriteString(e.path + "\x00" + e.title + "\x00")
+		dirents = append(dirents, d.Bytes())
+	}
+
+	var clusterBody bytes.Buffer
+	offset := uint32(4 * (len(blobs) + 1))
+	for _, b := range blobs {
+		binary.Write(&clusterBody, binary.LittleEndian, offset)
+		offset += uint32(len(b))
+	}
+	binary.Write(&clusterBody, binary.LittleEndian, offset)
+	for _, b := range blobs {
+		clusterBody.Write(b)
+	}
+
+	cluster := []byte{compression}
+	if compression == zimZlib {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(clusterBody.Bytes())
+		zw.Close()
+		cluster = append(cluster, z.Bytes()...)
+	} else {
+		cluster = append(cluster, clusterBody.Bytes()...)
+	}
+
+	// Lay the file out after the header.
+	var body bytes.Buffer
+	pos := func() uint64 { return 80 + uint64(body.Len()) }
+
+	h := ZimHeader{Magic: zimMagic, MajorVersion: 6, EntryCount: uint32(len(entries)), ClusterCount: 1}
+	h.MimeListPos = pos()
+	for _, m := range mimes {
+		body.WriteString(m + "\x00")
+	}
+	body.WriteByte(0)
+
+	direntPos := pos() + 16*uint64(len(entries)) // after both pointer lists
+	h.PathPtrPos = pos()
+	p := direntPos
+	for _, d := range dirents {
+		binary.Write(&body, binary.LittleEndian, p)
+		p += uint64(len(d))
+	}
+	h.TitlePtrPos = pos()
+	for i := range dirents {
+		binary.Write(&body, binary.LittleEndian, uint32(i))
+		body.Write([]byte{0, 0, 0, 0})
+	}
+	for _, d := range dirents {
+		body.Write(d)
+	}
+
+	h.ClusterPtrPos = pos()
+	binary.Write(&body, binary.LittleEndian, pos()+8)
+	body.Write(cluster)
+	h.ChecksumPos = pos()
+	body.Write(make([]byte, 16))
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, h)
+	if out.Len() != 80 {
+		t.Fatalf("header is %d bytes", out.Len())
+	}
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+var zimTestEntries = []zimTestEntry{
+	{ns: 'A', path: "Queen_(band)", title: "Queen (band)", mime: "text/html", content: `<p><a href="Freddie_Mercury">Freddie</a>
+		and <a class="new" href='./Brian_May#Life'>Brian</a>, <a href="https://queenonline.com">site</a>,
+		<a href="../I/Queen.jpg">photo</a>, <!-- <a href="Roger_Taylor"> --> <a href="Queen_(band)#Members">members</a></p>`},
+	{ns: 'A', path: "Freddie_Mercury", title: "Freddie Mercury", mime: "text/html", content: `<a href="Queen_%28band%29">Queen</a>`},
+	{ns: 'A', path: "Brian_May", mime: "text/html", content: `<A HREF=Queen>Queen</A>`},
+	{ns: 'A', path: "Queen", title: "Queen", redirect: "Queen_(band)"},
+	{ns: 'I', path: "Queen.jpg", mime: "image/jpeg", content: "\xff\xd8"},
+	{ns: 'M', path: "Title", mime: "text/plain", content: "Queen wiki"},
+}
+
+func loadTestZim(t *testing.T, data []byte) []*StrippedArticle {
+	zr, err := NewZimReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var articles []*StrippedArticle
+	err = LoadZim(zr, func(sa *StrippedArticle) bool {
+		articles = append(articles, sa)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return articles
+}
+
+func TestLoadZim(t *testing.T) {
+	for _, compression := range []byte{zimNone, zimZlib} {
+		articles := loadTestZim(t, buildZim(t, zimTestEntries, compression))
+
+		byTitle := make(map[string]*StrippedArticle)
+		for _, sa := range articles {
+			byTitle[sa.Title] = sa
+		}
+		assertEqual(t, len(articles), 4)
+		assertEqual(t, reflect.DeepEqual(byTitle["Queen (band)"].Links, []string{"Freddie Mercury", "Brian_May", "Queen (band)"}), true)
+		assertEqual(t, reflect.DeepEqual(byTitle["Freddie Mercury"].Links, []string{"Queen (band)"}), true)
+		assertEqual(t, reflect.DeepEqual(byTitle["Brian_May"].Links, []string{"Queen"}), true)
+		assertEqual(t, byTitle["Queen"].Redirect, "Queen (band)")
+		assertEqual(t, len(byTitle["Queen"].Links), 0)
+	}
+}
+
+func TestZimReader(t *testing.T) {
+	zr, err := NewZimReader(bytes.NewReader(buildZim(t, zimTestEntries, zimNone)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, zr.Header.EntryCount, uint32(6))
+	assertEqual(t, reflect.DeepEqual(zr.MimeTypes, []string{"text/html", "image/jpeg", "text/plain"}), true)
+
+	ze, err := zr.Entry(5)
+	assertEqual(t, err, nil)
+	assertEqual(t, ze.Path, "Title")
+	assertEqual(t, ze.Title, "Title")
+	blob, err := zr.Blob(ze)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(blob), "Queen wiki")
+
+	_, err = zr.Entry(6)
+	if err == nil {
+		t.Fatal("expected an error for an entry out of range")
+	}
+
+	_, err = NewZimReader(bytes.NewReader([]byte("not a zim file, but long enough for a header to be read from it........")))
+	if err == nil {
+		t.Fatal("expected an error for a file which isn't a ZIM")
+	}
+}
+
+func TestZimUnsupportedCompression(t *testing.T) {
+	zr, err := NewZimReader(bytes.NewReader(buildZim(t, zimTestEntries, zimZstd)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, zr.CheckCompression(), ErrZimCompression)
+	err = LoadZim(zr, func(*StrippedArticle) bool { return true })
+	if err == nil {
+		t.Fatal("expected an error for zstd clusters")
+	}
+
+	zr, _ = NewZimReader(bytes.NewReader(buildZim(t, zimTestEntries, zimZlib)))
+	assertEqual(t, zr.CheckCompression(), nil)
+}